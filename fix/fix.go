@@ -0,0 +1,186 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+// Package fix applies the fixes attached to report entries to json and yaml
+// documents.
+package fix
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/coreos/vcontext/json"
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+	yaml "github.com/coreos/vcontext/yaml"
+)
+
+var (
+	ErrConflict = errors.New("fixes edit overlapping text")
+	ErrBadEdit  = errors.New("invalid text edit")
+	ErrBadOp    = errors.New("invalid patch operation")
+)
+
+// ApplyJSON applies fixes to the json document src and returns the result.
+// Text edits are applied first, all at once, so their markers refer to src.
+// If any fix has patch operations, the result is then parsed, patched in
+// order and serialized again with two space indentation.
+func ApplyJSON(src []byte, fixes []report.Fix) ([]byte, error) {
+	return apply(src, fixes, func(b []byte) (tree.Node, error) {
+		return json.UnmarshalToContext(b)
+	}, func(n tree.Node) ([]byte, error) {
+		return json.MarshalIndent(n, "", "  ")
+	})
+}
+
+// ApplyYAML is like ApplyJSON, but for yaml documents. Comments and styles
+// survive patching.
+func ApplyYAML(src []byte, fixes []report.Fix) ([]byte, error) {
+	return apply(src, fixes, func(b []byte) (tree.Node, error) {
+		return yaml.UnmarshalToContext(b)
+	}, yaml.Marshal)
+}
+
+func apply(src []byte, fixes []report.Fix, parse func([]byte) (tree.Node, error), marshal func(tree.Node) ([]byte, error)) ([]byte, error) {
+	var edits []report.TextEdit
+	var ops []report.PatchOp
+	for _, f := range fixes {
+		edits = append(edits, f.Edits...)
+		ops = append(ops, f.Patch...)
+	}
+	out, err := ApplyEdits(src, edits)
+	if err != nil || len(ops) == 0 {
+		return out, err
+	}
+	n, err := parse(out)
+	if err != nil {
+		return nil, err
+	}
+	if n, err = Patch(n, ops); err != nil {
+		return nil, err
+	}
+	return marshal(n)
+}
+
+type span struct {
+	start, end int64
+	text       string
+}
+
+// ApplyEdits applies text edits to src. It returns ErrConflict if two edits
+// overlap or insert at the same place.
+func ApplyEdits(src []byte, edits []report.TextEdit) ([]byte, error) {
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		if e.Marker.StartP == nil {
+			return nil, ErrBadEdit
+		}
+		s := span{
+			start: e.Marker.StartP.Index,
+			end:   e.Marker.StartP.Index,
+			text:  e.NewText,
+		}
+		if e.Marker.EndP != nil {
+			// markers' ends are inclusive
+			s.end = e.Marker.EndP.Index + 1
+		}
+		if s.start < 0 || s.end < s.start || s.end > int64(len(src)) {
+			return nil, ErrBadEdit
+		}
+		spans = append(spans, s)
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+	out := make([]byte, 0, len(src))
+	prev := int64(0)
+	for i, s := range spans {
+		if s.start < prev || (i > 0 && s.start == spans[i-1].start) {
+			return nil, ErrConflict
+		}
+		out = append(out, src[prev:s.start]...)
+		out = append(out, s.text...)
+		prev = s.end
+	}
+	return append(out, src[prev:]...), nil
+}
+
+// Patch applies patch operations to n in order and returns the result. n
+// is not modified.
+func Patch(n tree.Node, ops []report.PatchOp) (tree.Node, error) {
+	for _, op := range ops {
+		var err error
+		switch op.Op {
+		case report.OpAdd:
+			if isIndex(op.Path) {
+				n, err = tree.Insert(n, op.Path, ToNode(op.Value))
+			} else {
+				n, err = tree.Set(n, op.Path, ToNode(op.Value))
+			}
+		case report.OpRemove:
+			n, err = tree.Delete(n, op.Path)
+		case report.OpReplace:
+			if _, err = n.Get(op.Path); err == nil {
+				n, err = tree.Set(n, op.Path, ToNode(op.Value))
+			}
+		case report.OpMove:
+			n, err = tree.Move(n, op.From, op.Path)
+		default:
+			err = ErrBadOp
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return n, nil
+}
+
+func isIndex(c path.ContextPath) bool {
+	if c.Len() == 0 {
+		return false
+	}
+	_, ok := c.Path[c.Len()-1].(int)
+	return ok
+}
+
+// ToNode converts a value, as decoded by encoding/json into an
+// interface{}, to a tree without markers.
+func ToNode(v interface{}) tree.Node {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		ret := tree.MapNode{
+			Children: make(map[string]tree.Node, len(val)),
+			Keys:     make(map[string]tree.Leaf, len(val)),
+		}
+		for k, child := range val {
+			ret.Children[k] = ToNode(child)
+			ret.Keys[k] = tree.Leaf{}
+		}
+		return ret
+	case []interface{}:
+		ret := tree.SliceNode{
+			Children: make([]tree.Node, 0, len(val)),
+		}
+		for _, child := range val {
+			ret.Children = append(ret.Children, ToNode(child))
+		}
+		return ret
+	default:
+		return tree.Leaf{
+			Value: v,
+		}
+	}
+}
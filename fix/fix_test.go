@@ -0,0 +1,284 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package fix
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/coreos/vcontext/json"
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+	yaml "github.com/coreos/vcontext/yaml"
+)
+
+func edit(start, end int64, text string) report.TextEdit {
+	m := tree.Marker{StartP: &tree.Pos{Index: start}}
+	if end >= 0 {
+		m.EndP = &tree.Pos{Index: end}
+	}
+	return report.TextEdit{Marker: m, NewText: text}
+}
+
+func TestApplyEdits(t *testing.T) {
+	src := []byte(`{"a": 1, "b": 2}`)
+	tests := []struct {
+		edits []report.TextEdit
+		out   string
+		err   error
+	}{
+		{
+			out: `{"a": 1, "b": 2}`,
+		},
+		{
+			edits: []report.TextEdit{edit(6, 6, "3")},
+			out:   `{"a": 3, "b": 2}`,
+		},
+		// order of edits doesn't matter
+		{
+			edits: []report.TextEdit{edit(14, 14, "4"), edit(6, 6, "3")},
+			out:   `{"a": 3, "b": 4}`,
+		},
+		{
+			edits: []report.TextEdit{edit(7, -1, `, "c": 0`)},
+			out:   `{"a": 1, "c": 0, "b": 2}`,
+		},
+		{
+			edits: []report.TextEdit{edit(6, 14, "1"), edit(9, 11, "")},
+			err:   ErrConflict,
+		},
+		{
+			edits: []report.TextEdit{edit(7, -1, "x"), edit(7, -1, "y")},
+			err:   ErrConflict,
+		},
+		{
+			edits: []report.TextEdit{edit(14, 30, "")},
+			err:   ErrBadEdit,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := ApplyEdits(src, test.edits)
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestApplyJSON(t *testing.T) {
+	src := []byte(`{"a": 1, "list": [1, 2], "old": true, "big": 12345678901234567890}`)
+	tests := []struct {
+		fixes []report.Fix
+		out   string
+		err   bool
+	}{
+		{
+			fixes: []report.Fix{
+				{
+					Patch: []report.PatchOp{
+						{Op: report.OpReplace, Path: path.New("", "a"), Value: "x"},
+						{Op: report.OpAdd, Path: path.New("", "list", 0), Value: float64(0)},
+						{Op: report.OpMove, From: path.New("", "old"), Path: path.New("", "new")},
+					},
+				},
+			},
+			out: "{\n  \"a\": \"x\",\n  \"list\": [\n    0,\n    1,\n    2\n  ],\n  \"big\": 12345678901234567890,\n  \"new\": true\n}",
+		},
+		{
+			fixes: []report.Fix{
+				{
+					Patch: []report.PatchOp{
+						{Op: report.OpRemove, Path: path.New("", "list")},
+						{Op: report.OpAdd, Path: path.New("", "obj"), Value: map[string]interface{}{"k": []interface{}{nil}}},
+					},
+				},
+			},
+			out: "{\n  \"a\": 1,\n  \"old\": true,\n  \"big\": 12345678901234567890,\n  \"obj\": {\n    \"k\": [\n      null\n    ]\n  }\n}",
+		},
+		// text edits come first
+		{
+			fixes: []report.Fix{
+				{
+					Edits: []report.TextEdit{edit(2, 2, "b")},
+				},
+				{
+					Patch: []report.PatchOp{
+						{Op: report.OpRemove, Path: path.New("", "b")},
+						{Op: report.OpRemove, Path: path.New("", "list")},
+						{Op: report.OpRemove, Path: path.New("", "big")},
+					},
+				},
+			},
+			out: "{\n  \"old\": true\n}",
+		},
+		// replace requires the value to exist
+		{
+			fixes: []report.Fix{
+				{
+					Patch: []report.PatchOp{
+						{Op: report.OpReplace, Path: path.New("", "missing"), Value: 1.0},
+					},
+				},
+			},
+			err: true,
+		},
+		{
+			fixes: []report.Fix{
+				{
+					Patch: []report.PatchOp{
+						{Op: "copy", Path: path.New("", "a")},
+					},
+				},
+			},
+			err: true,
+		},
+	}
+
+	for i, test := range tests {
+		out, err := ApplyJSON(src, test.fixes)
+		if (err != nil) != test.err {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, out)
+		}
+	}
+}
+
+func TestApplyYAML(t *testing.T) {
+	// untouched scalars are written as they were
+	src := []byte("# config\na: 1 # keep me\nb: [1, 2]\nmode: 0o17\ndate: 2001-12-14\nbig: 12345678901234567890\n")
+	fixes := []report.Fix{
+		{
+			Patch: []report.PatchOp{
+				{Op: report.OpRemove, Path: path.New("", "b", 0)},
+				{Op: report.OpAdd, Path: path.New("", "c"), Value: "z"},
+			},
+		},
+	}
+	expected := "# config\na: 1 # keep me\nb: [2]\nmode: 0o17\ndate: 2001-12-14\nbig: 12345678901234567890\nc: z\n"
+
+	out, err := ApplyYAML(src, fixes)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestApplyCorrelatedEdits(t *testing.T) {
+	tests := []struct {
+		yaml bool
+		src  string
+		path path.ContextPath
+		text string
+		out  string
+		err  error
+	}{
+		// maps, slices and keys are replaced whole
+		{
+			src:  `{"a": {"b": 1}, "c": 2}`,
+			path: path.New("json", "a"),
+			text: `[]`,
+			out:  `{"a": [], "c": 2}`,
+		},
+		{
+			src:  `{"a": [1, 2], "c": 2}`,
+			path: path.New("json", "a"),
+			text: `{}`,
+			out:  `{"a": {}, "c": 2}`,
+		},
+		{
+			src:  `{"a" : 1}`,
+			path: path.New("json", tree.Key("a")),
+			text: `"b"`,
+			out:  `{"b" : 1}`,
+		},
+		{
+			src:  `{"a": "x\"y"}`,
+			path: path.New("json", "a"),
+			text: `1`,
+			out:  `{"a": 1}`,
+		},
+		{
+			yaml: true,
+			src:  "a: {ä: [old, 'it''s'], \"k\": \"v\\\"\"}\n",
+			path: path.New("yaml", "a", "ä", 1),
+			text: "new",
+			out:  "a: {ä: [old, new], \"k\": \"v\\\"\"}\n",
+		},
+		{
+			yaml: true,
+			src:  "a: {ä: [old, 'it''s'], \"k\": \"v\\\"\"}\n",
+			path: path.New("yaml", "a", "k"),
+			text: "x",
+			out:  "a: {ä: [old, 'it''s'], \"k\": x}\n",
+		},
+		{
+			yaml: true,
+			src:  "a: 1\nbb: 2\n",
+			path: path.New("yaml", tree.Key("bb")),
+			text: "b",
+			out:  "a: 1\nb: 2\n",
+		},
+		// edits fail rather than inserting if the node can't be replaced
+		{
+			src:  `{"a": 1}`,
+			path: path.New("json", "b"),
+			text: `2`,
+			err:  ErrBadEdit,
+		},
+		{
+			yaml: true,
+			src:  "a:\n  b: 1\n",
+			path: path.New("yaml", "a"),
+			text: "2",
+			err:  ErrBadEdit,
+		},
+	}
+
+	for i, test := range tests {
+		var n tree.Node
+		var err error
+		if test.yaml {
+			n, err = yaml.UnmarshalToContext([]byte(test.src))
+		} else {
+			n, err = json.UnmarshalToContext([]byte(test.src))
+		}
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		var r report.Report
+		r.AddOnError(test.path, report.WithFixes(errors.New("bad"), report.Fix{
+			Edits: []report.TextEdit{{Path: test.path, NewText: test.text}},
+		}))
+		r.Correlate(n)
+		out, err := ApplyEdits([]byte(test.src), r.Fixes()[0].Edits)
+		if !errors.Is(err, test.err) {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && string(out) != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, out)
+		}
+	}
+}
@@ -20,18 +20,20 @@ import (
 	json "github.com/coreos/go-json"
 )
 
-func UnmarshalToContext(raw []byte) (tree.Node, error) {
+func UnmarshalToContext(raw []byte, opts ...tree.Option) (tree.Node, error) {
+	o := tree.NewOptions(opts...)
 	var ast json.Node
 	if err := json.Unmarshal(raw, &ast); err != nil {
 		return nil, err
 	}
-	node := fromJsonNode(ast)
-	tree.FixLineColumn(node, raw)
+	node := fromJsonNode(ast, raw, o.Source)
+	tree.FixLineColumnWithOptions(node, raw, o.ColumnsOr(tree.ColumnOptions{}))
 	return node, nil
 }
 
-func fromJsonNode(n json.Node) tree.Node {
+func fromJsonNode(n json.Node, raw []byte, source string) tree.Node {
 	m := tree.MarkerFromIndices(int64(n.Start), int64(n.End))
+	m.Source = source
 
 	switch v := n.Value.(type) {
 	case map[string]json.Node:
@@ -41,9 +43,11 @@ func fromJsonNode(n json.Node) tree.Node {
 			Keys:     make(map[string]tree.Leaf, len(v)),
 		}
 		for key, child := range v {
-			ret.Children[key] = fromJsonNode(child)
+			ret.Children[key] = fromJsonNode(child, raw, source)
+			km := tree.MarkerFromIndices(int64(child.KeyStart), int64(child.KeyEnd))
+			km.Source = source
 			ret.Keys[key] = tree.Leaf{
-				Marker: tree.MarkerFromIndices(int64(child.KeyStart), int64(child.KeyEnd)),
+				Marker: km,
 			}
 		}
 		return ret
@@ -53,12 +57,23 @@ func fromJsonNode(n json.Node) tree.Node {
 			Children: make([]tree.Node, 0, len(v)),
 		}
 		for _, child := range v {
-			ret.Children = append(ret.Children, fromJsonNode(child))
+			ret.Children = append(ret.Children, fromJsonNode(child, raw, source))
 		}
 		return ret
+	case float64:
+		// keep numbers as written, since e.g. big integers don't fit in a
+		// float64
+		return tree.Leaf{
+			Marker: m,
+			Value:  v,
+			Format: tree.Format{
+				Text: string(raw[n.Start : n.End+1]),
+			},
+		}
 	default:
 		return tree.Leaf{
 			Marker: m,
+			Value:  v,
 		}
 	}
 }
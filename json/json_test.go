@@ -18,6 +18,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/tree"
 
 	json "github.com/coreos/go-json"
@@ -37,6 +38,7 @@ func TestUnmarshalToContext(t *testing.T) {
 			},
 			tree.Leaf{
 				Marker: tree.MarkerFromIndices(1, 2),
+				Value:  "foo",
 			},
 		},
 		// map
@@ -118,6 +120,7 @@ func TestUnmarshalToContext(t *testing.T) {
 					},
 					"baz": tree.Leaf{
 						Marker: tree.MarkerFromIndices(13, 14),
+						Value:  "quux",
 					},
 				},
 			},
@@ -140,6 +143,7 @@ func TestUnmarshalToContext(t *testing.T) {
 				Children: []tree.Node{
 					tree.Leaf{
 						Marker: tree.MarkerFromIndices(3, 4),
+						Value:  "foo",
 					},
 				},
 			},
@@ -193,9 +197,54 @@ func TestUnmarshalToContext(t *testing.T) {
 		},
 	}
 	for i, test := range tests {
-		n := fromJsonNode(test.in)
+		n := fromJsonNode(test.in, nil, "")
 		if !reflect.DeepEqual(test.out, n) {
 			t.Errorf("test %d failed: expected: %v, got %v", i, test.out, n)
 		}
 	}
 }
+
+func TestUnmarshalWithSource(t *testing.T) {
+	n, err := UnmarshalToContext([]byte("{\n  \"foo\": 1\n}"), tree.WithSource("config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := n.Get(path.New("", "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := child.GetMarker().String(); s != "config.json:2:10" {
+		t.Errorf("expected config.json:2:10, got %q", s)
+	}
+	key, err := n.Get(path.New("", tree.Key("foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := key.GetMarker().String(); s != "config.json:2:3" {
+		t.Errorf("expected config.json:2:3, got %q", s)
+	}
+}
+
+func TestUnmarshalLineEndings(t *testing.T) {
+	for _, nl := range []string{"\n", "\r\n", "\r"} {
+		src := "{" + nl + "  \"foo\": {" + nl + nl + "    \"bar\": 1" + nl + "  }" + nl + "}"
+		n, err := UnmarshalToContext([]byte(src))
+		if err != nil {
+			t.Fatalf("%q: %v", nl, err)
+		}
+		bar, err := n.Get(path.New("", "foo", "bar"))
+		if err != nil {
+			t.Fatalf("%q: %v", nl, err)
+		}
+		if line, col := bar.Start(); line != 4 || col != 12 {
+			t.Errorf("%q: expected 4:12, got %d:%d", nl, line, col)
+		}
+		key, err := n.Get(path.New("", "foo", tree.Key("bar")))
+		if err != nil {
+			t.Fatalf("%q: %v", nl, err)
+		}
+		if line, col := key.Start(); line != 4 || col != 5 {
+			t.Errorf("%q: expected 4:5, got %d:%d", nl, line, col)
+		}
+	}
+}
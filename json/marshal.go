@@ -0,0 +1,123 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/coreos/vcontext/tree"
+
+	json "github.com/coreos/go-json"
+)
+
+// Marshal returns the json encoding of the tree n. Maps are written with
+// their keys in source order (see tree.MapNode.OrderedKeys), and numbers
+// keep the text they were written with unless their value changed.
+// Formatting json cannot represent, such as comments, is dropped.
+func Marshal(n tree.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshal(&buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalIndent is like Marshal but indents the output like
+// encoding/json.MarshalIndent.
+func MarshalIndent(n tree.Node, prefix, indent string) ([]byte, error) {
+	b, err := Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshal(buf *bytes.Buffer, n tree.Node) error {
+	switch v := n.(type) {
+	case nil:
+		buf.WriteString("null")
+	case tree.MapNode:
+		buf.WriteByte('{')
+		first := true
+		for _, k := range v.OrderedKeys() {
+			child, ok := v.Children[k]
+			if !ok {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			if err := marshalValue(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := marshal(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case tree.SliceNode:
+		buf.WriteByte('[')
+		for i, child := range v.Children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := marshal(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case tree.Leaf:
+		if isText(v.Format.Text, v.Value) {
+			buf.WriteString(v.Format.Text)
+			return nil
+		}
+		return marshalValue(buf, v.Value)
+	default:
+		return fmt.Errorf("cannot marshal node of type %T", n)
+	}
+	return nil
+}
+
+// isText returns whether text is json encoding value, so it can be written
+// instead of encoding value again.
+func isText(text string, value interface{}) bool {
+	if text == "" {
+		return false
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(v, value)
+}
+
+func marshalValue(buf *bytes.Buffer, v interface{}) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	// Encode terminates values with a newline
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package json
+
+import (
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{`null`, `null`},
+		{`["a<b"]`, `["a<b"]`},
+		{`{}`, `{}`},
+		{`[]`, `[]`},
+		// key order is preserved
+		{
+			`{"z": 1, "a": [true, null, "x", 2.5], "m": {"y": {}, "b": []}}`,
+			`{"z":1,"a":[true,null,"x",2.5],"m":{"y":{},"b":[]}}`,
+		},
+		// numbers are written as they were
+		{
+			`[12345678901234567890, 1.50, 1E3, -0]`,
+			`[12345678901234567890,1.50,1E3,-0]`,
+		},
+	}
+
+	for i, test := range tests {
+		n, err := UnmarshalToContext([]byte(test.in))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		out, err := Marshal(n)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: expected %s, got %s", i, test.out, out)
+		}
+	}
+}
+
+func TestMarshalEdited(t *testing.T) {
+	n, err := UnmarshalToContext([]byte(`{"b": 1, "a": 2, "d": 1.0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// edited values don't keep their text
+	d, err := n.Get(path.New("", "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := d.(tree.Leaf)
+	leaf.Value = 1.5
+	if n, err = tree.Set(n, path.New("", "d"), leaf); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = tree.Set(n, path.New("", "c"), tree.Leaf{Value: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = tree.Delete(n, path.New("", "b")); err != nil {
+		t.Fatal(err)
+	}
+	out, err := MarshalIndent(n, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\n  \"a\": 2,\n  \"d\": 1.5,\n  \"c\": \"new\"\n}"
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
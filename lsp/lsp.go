@@ -0,0 +1,306 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+// Package lsp implements a Language Server Protocol server which publishes
+// the entries of a report.Report as diagnostics while a document is edited.
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+)
+
+// ValidateFunc parses and validates the document with the given URI and
+// contents. The returned report should be correlated against the
+// document's tree so its entries have markers. Parse errors should be
+// reported as entries too, since the server has no other way to show them.
+type ValidateFunc func(uri string, text []byte) report.Report
+
+// Server is a language server publishing diagnostics for the documents a
+// client opens.
+type Server struct {
+	// Name is sent to the client as the server name and as the source of
+	// every diagnostic.
+	Name string
+	// ResolveSource, if set, returns the URI and contents of the document
+	// named by the Source of a marker, e.g. for entries correlated with
+	// report.CorrelateProvenance. Related locations in other documents
+	// than the one being validated are omitted if it is nil or returns
+	// false.
+	ResolveSource func(source string) (uri string, text []byte, ok bool)
+
+	validate ValidateFunc
+	conn     *Conn
+}
+
+// NewServer returns a Server which validates documents with validate.
+func NewServer(validate ValidateFunc) *Server {
+	return &Server{
+		Name:     "vcontext",
+		validate: validate,
+	}
+}
+
+// Serve reads requests from in and writes responses and notifications to
+// out, e.g. os.Stdin and os.Stdout, until the client sends an exit
+// notification or in is closed.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.conn = NewConn(in, out)
+	for {
+		body, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg message) error {
+	var result interface{}
+	var rerr *responseError
+	switch msg.Method {
+	case "":
+		rerr = &responseError{Code: codeInvalidRequest, Message: "missing method"}
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					// full document sync
+					"change": 1,
+				},
+			},
+			"serverInfo": map[string]interface{}{
+				"name": s.Name,
+			},
+		}
+	case "shutdown":
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if rerr = unmarshalParams(msg.Params, &p); rerr == nil {
+			return s.update(p.TextDocument.URI, []byte(p.TextDocument.Text))
+		}
+	case "textDocument/didChange":
+		var p didChangeParams
+		if rerr = unmarshalParams(msg.Params, &p); rerr == nil && len(p.ContentChanges) > 0 {
+			// with full sync the last change holds the whole document
+			return s.update(p.TextDocument.URI, []byte(p.ContentChanges[len(p.ContentChanges)-1].Text))
+		}
+	case "textDocument/didClose":
+		var p didCloseParams
+		if rerr = unmarshalParams(msg.Params, &p); rerr == nil {
+			// clear the diagnostics of closed documents
+			return s.publish(p.TextDocument.URI, []Diagnostic{})
+		}
+	default:
+		rerr = &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+
+	// notifications never get a response, even on error
+	if msg.ID == nil {
+		return nil
+	}
+	return s.reply(msg.ID, result, rerr)
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) update(uri string, text []byte) error {
+	r := s.validate(uri, text)
+	return s.publish(uri, s.Diagnostics(uri, r, text))
+}
+
+// Diagnostics converts the entries of r to diagnostics for the document
+// text with the given URI. Entries and related locations without a marker
+// are placed at the start of the document, as are entries whose marker
+// refers to another document. Markers with no Source, or with uri as their
+// Source, refer to the document; see ResolveSource for related locations in
+// others.
+func (s *Server) Diagnostics(uri string, r report.Report, text []byte) []Diagnostic {
+	lines := tree.NewLineIndex(text, tree.ColumnOptions{Mode: tree.UTF16Columns})
+	ret := make([]Diagnostic, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		d := Diagnostic{
+			Severity: severity(e.Kind),
+			Code:     e.Code,
+			Source:   s.Name,
+			Message:  e.Message,
+		}
+		if e.HelpURL != "" {
+			d.CodeDescription = &CodeDescription{Href: e.HelpURL}
+		}
+		if inDocument(uri, e.Marker) {
+			d.Range = markerRange(lines, text, e.Marker)
+		}
+		for _, rel := range e.Related {
+			loc, ok := s.location(uri, text, lines, rel.Marker)
+			if !ok {
+				continue
+			}
+			msg := rel.Note
+			if msg == "" {
+				msg = rel.Context.String()
+			}
+			d.RelatedInformation = append(d.RelatedInformation, DiagnosticRelatedInformation{
+				Location: loc,
+				Message:  msg,
+			})
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// location returns the location of m, which is in the document text with
+// the given URI unless its Source names another one.
+func (s *Server) location(uri string, text []byte, lines *tree.LineIndex, m tree.Marker) (Location, bool) {
+	if !inDocument(uri, m) {
+		if s.ResolveSource == nil {
+			return Location{}, false
+		}
+		var ok bool
+		if uri, text, ok = s.ResolveSource(m.Source); !ok {
+			return Location{}, false
+		}
+		lines = tree.NewLineIndex(text, tree.ColumnOptions{Mode: tree.UTF16Columns})
+	}
+	return Location{
+		URI:   uri,
+		Range: markerRange(lines, text, m),
+	}, true
+}
+
+// inDocument returns whether m refers to the document with the given URI.
+func inDocument(uri string, m tree.Marker) bool {
+	return m.Source == "" || m.Source == uri
+}
+
+func markerRange(lines *tree.LineIndex, text []byte, m tree.Marker) Range {
+	var ret Range
+	if m.StartP != nil {
+		ret.Start = position(lines, text, m.StartP, false)
+		ret.End = ret.Start
+	}
+	if m.EndP != nil {
+		ret.End = position(lines, text, m.EndP, true)
+	}
+	return ret
+}
+
+func severity(k report.EntryKind) int {
+	switch k {
+	case report.Error:
+		return SeverityError
+	case report.Warn:
+		return SeverityWarning
+	case report.Info:
+		return SeverityInformation
+	}
+	if k.IsFatal() {
+		return SeverityError
+	}
+	return SeverityInformation
+}
+
+// position converts the offset of p in text to a zero-based line and UTF-16
+// offset, as counted by lines. Line and column of p are ignored, since
+// they may have been counted in other units. Markers' end positions point
+// at the last character of a node, while LSP ranges are exclusive, so if
+// after is set the position following the character is returned.
+func position(lines *tree.LineIndex, text []byte, p *tree.Pos, after bool) Position {
+	offset := p.Index
+	if after && offset >= 0 && offset < int64(len(text)) {
+		_, size := utf8.DecodeRune(text[offset:])
+		offset += int64(size)
+	}
+	pos, err := lines.Position(offset)
+	if err != nil {
+		return Position{}
+	}
+	return Position{
+		Line:      int(pos.Line - 1),
+		Character: int(pos.Column - 1),
+	}
+}
+
+func (s *Server) publish(uri string, diags []Diagnostic) error {
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diags,
+	})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(message{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  p,
+	})
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	msg := message{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   rerr,
+	}
+	if id == nil {
+		// JSON-RPC requires a null id when the request's id is unknown
+		null := json.RawMessage("null")
+		msg.ID = &null
+	}
+	if rerr == nil {
+		r, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = r
+	}
+	return s.write(msg)
+}
+
+func (s *Server) write(msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.conn.Write(body)
+}
@@ -0,0 +1,350 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	vjson "github.com/coreos/vcontext/json"
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/tree"
+	vyaml "github.com/coreos/vcontext/yaml"
+)
+
+// validateTest reports an error on "x" if it is not a number, related to
+// the value of "😀", and a warning on the whole document.
+func validateTest(uri string, text []byte) (r report.Report) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(text, &doc); err != nil {
+		r.AddOnError(path.ContextPath{}, err)
+		return
+	}
+	if _, ok := doc["x"].(float64); !ok {
+		err := report.WithRelated(errors.New("x must be a number"), report.Related{
+			Context: path.New("json", "😀"),
+			Note:    "like this",
+		})
+		r.AddOnErrorWithCode(path.New("json", "x"), err, "T0001")
+	}
+	r.AddOnWarn(path.ContextPath{}, errors.New("document is a test"))
+	n, err := vjson.UnmarshalToContext(text)
+	if err != nil {
+		r.AddOnError(path.ContextPath{}, err)
+		return
+	}
+	r.Correlate(n)
+	return
+}
+
+type testClient struct {
+	t    *testing.T
+	conn *Conn
+	done chan error
+}
+
+func newTestClient(t *testing.T, validate ValidateFunc) *testClient {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &testClient{
+		t:    t,
+		conn: NewConn(clientIn, clientOut),
+		done: make(chan error, 1),
+	}
+	go func() {
+		err := NewServer(validate).Serve(serverIn, serverOut)
+		serverOut.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *testClient) send(id int, method string, params interface{}) {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	}
+	if id != 0 {
+		msg["id"] = id
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.conn.Write(body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) receive() message {
+	body, err := c.conn.Read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func (c *testClient) receiveDiagnostics() PublishDiagnosticsParams {
+	msg := c.receive()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+	var p PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+func TestServer(t *testing.T) {
+	c := newTestClient(t, validateTest)
+
+	c.send(1, "initialize", map[string]interface{}{})
+	if msg := c.receive(); string(*msg.ID) != "1" || msg.Error != nil {
+		t.Fatalf("bad initialize response: %+v", msg)
+	}
+	c.send(0, "initialized", map[string]interface{}{})
+
+	// "x" is preceded by a character outside the BMP, which is one rune,
+	// four bytes and two UTF-16 code units
+	c.send(0, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        "file:///test.json",
+			"languageId": "json",
+			"version":    1,
+			"text":       "{\n\"😀\": 1, \"x\": true\n}",
+		},
+	})
+	p := c.receiveDiagnostics()
+	expected := PublishDiagnosticsParams{
+		URI: "file:///test.json",
+		Diagnostics: []Diagnostic{
+			{
+				Range: Range{
+					Start: Position{Line: 1, Character: 14},
+					End:   Position{Line: 1, Character: 18},
+				},
+				Severity: SeverityError,
+				Code:     "T0001",
+				Source:   "vcontext",
+				Message:  "x must be a number",
+				RelatedInformation: []DiagnosticRelatedInformation{
+					{
+						Location: Location{
+							URI: "file:///test.json",
+							Range: Range{
+								Start: Position{Line: 1, Character: 6},
+								End:   Position{Line: 1, Character: 7},
+							},
+						},
+						Message: "like this",
+					},
+				},
+			},
+			// go-json starts objects after the opening brace
+			{
+				Range: Range{
+					Start: Position{Line: 0, Character: 1},
+					End:   Position{Line: 2, Character: 1},
+				},
+				Severity: SeverityWarning,
+				Source:   "vcontext",
+				Message:  "document is a test",
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, p) {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+
+	c.send(0, "textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":     "file:///test.json",
+			"version": 2,
+		},
+		"contentChanges": []map[string]interface{}{
+			{"text": `{"x": 1}`},
+		},
+	})
+	if p := c.receiveDiagnostics(); len(p.Diagnostics) != 1 || p.Diagnostics[0].Severity != SeverityWarning {
+		t.Errorf("expected only the warning after fixing x, got %+v", p)
+	}
+
+	c.send(0, "textDocument/didClose", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri": "file:///test.json",
+		},
+	})
+	if p := c.receiveDiagnostics(); p.Diagnostics == nil || len(p.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", p)
+	}
+
+	c.send(2, "no/such/method", nil)
+	if msg := c.receive(); msg.Error == nil || msg.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %+v", msg)
+	}
+
+	c.send(3, "shutdown", nil)
+	if msg := c.receive(); string(*msg.ID) != "3" || msg.Error != nil || string(msg.Result) != "null" {
+		t.Errorf("bad shutdown response: %+v", msg)
+	}
+	c.send(0, "exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("server returned %v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		unmarshal func([]byte, ...tree.Option) (tree.Node, error)
+		opts      []tree.Option
+		text      string
+		path      path.ContextPath
+		expected  Range
+	}{
+		// yaml counts columns in runes, but LSP counts UTF-16 code units
+		{
+			vyaml.UnmarshalToContext,
+			nil,
+			"a: {ééé: 1, b: 2}",
+			path.New("yaml", "a", "b"),
+			Range{Start: Position{Line: 0, Character: 15}, End: Position{Line: 0, Character: 16}},
+		},
+		{
+			vyaml.UnmarshalToContext,
+			nil,
+			"x: 1\r\n😀: [ä, b]\r\n",
+			path.New("yaml", "😀", 1),
+			Range{Start: Position{Line: 1, Character: 8}, End: Position{Line: 1, Character: 9}},
+		},
+		// the column mode the tree was parsed with doesn't matter
+		{
+			vjson.UnmarshalToContext,
+			nil,
+			`{"😀ä": 1, "b": "äé"}`,
+			path.New("json", "b"),
+			Range{Start: Position{Line: 0, Character: 16}, End: Position{Line: 0, Character: 20}},
+		},
+		{
+			vjson.UnmarshalToContext,
+			[]tree.Option{tree.WithColumns(tree.ColumnOptions{Mode: tree.RuneColumns})},
+			`{"😀ä": 1, "b": "äé"}`,
+			path.New("json", "b"),
+			Range{Start: Position{Line: 0, Character: 16}, End: Position{Line: 0, Character: 20}},
+		},
+	}
+
+	s := NewServer(nil)
+	for i, test := range tests {
+		n, err := test.unmarshal([]byte(test.text), test.opts...)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		var r report.Report
+		r.AddOnError(test.path, errors.New("bad"))
+		r.Correlate(n)
+		d := s.Diagnostics("file:///test", r, []byte(test.text))
+		if len(d) != 1 || d[0].Range != test.expected {
+			t.Errorf("#%d: expected %+v, got %+v", i, test.expected, d)
+		}
+	}
+}
+
+func TestDiagnosticsRelatedSources(t *testing.T) {
+	text := []byte(`{"a": 1}`)
+	base := []byte("x: 1\né: 2\n")
+	doc, err := vjson.UnmarshalToContext(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := vyaml.UnmarshalToContext(base, tree.WithSource("base.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := doc.Get(path.New("", "a"))
+	e, _ := other.Get(path.New("", "é"))
+	r := report.Report{
+		Entries: []report.Entry{
+			{
+				Kind:    report.Error,
+				Message: "bad",
+				Related: []report.Related{
+					{Marker: e.GetMarker(), Note: "base"},
+					{Marker: a.GetMarker(), Note: "here"},
+				},
+			},
+		},
+	}
+	here := DiagnosticRelatedInformation{
+		Location: Location{
+			URI:   "file:///doc.json",
+			Range: Range{Start: Position{Line: 0, Character: 6}, End: Position{Line: 0, Character: 7}},
+		},
+		Message: "here",
+	}
+
+	// locations in other documents are omitted unless they can be resolved
+	s := NewServer(nil)
+	d := s.Diagnostics("file:///doc.json", r, text)
+	if expected := []DiagnosticRelatedInformation{here}; len(d) != 1 || !reflect.DeepEqual(d[0].RelatedInformation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, d)
+	}
+
+	s.ResolveSource = func(source string) (string, []byte, bool) {
+		if source != "base.yaml" {
+			return "", nil, false
+		}
+		return "file:///base.yaml", base, true
+	}
+	d = s.Diagnostics("file:///doc.json", r, text)
+	expected := []DiagnosticRelatedInformation{
+		{
+			Location: Location{
+				URI:   "file:///base.yaml",
+				Range: Range{Start: Position{Line: 1, Character: 3}, End: Position{Line: 1, Character: 4}},
+			},
+			Message: "base",
+		},
+		here,
+	}
+	if len(d) != 1 || !reflect.DeepEqual(d[0].RelatedInformation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, d)
+	}
+
+	// entries in other documents are placed at the start of this one
+	x, _ := other.Get(path.New("", "x"))
+	self := a.GetMarker()
+	self.Source = "file:///doc.json"
+	r = report.Report{
+		Entries: []report.Entry{
+			{Kind: report.Error, Message: "elsewhere", Marker: x.GetMarker()},
+			{Kind: report.Error, Message: "here", Marker: self},
+		},
+	}
+	d = s.Diagnostics("file:///doc.json", r, text)
+	ranges := []Range{{}, here.Location.Range}
+	if len(d) != 2 || d[0].Range != ranges[0] || d[1].Range != ranges[1] {
+		t.Errorf("expected ranges %+v, got %+v", ranges, d)
+	}
+}
@@ -15,10 +15,15 @@
 package path
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
 
+var (
+	ErrBadPattern = errors.New("invalid path pattern")
+)
+
 type ContextPath struct {
 	Path []interface{}
 	Tag  string
@@ -97,3 +102,128 @@ func (c ContextPath) Pop() ContextPath {
 func (c ContextPath) Len() int {
 	return len(c.Path)
 }
+
+// Equal returns true if c and o refer to the same path. Tags are ignored.
+func (c ContextPath) Equal(o ContextPath) bool {
+	return Compare(c, o) == 0
+}
+
+// Compare orders two paths element by element, returning -1, 0 or 1. Ints
+// compare numerically and sort before strings; other elements compare by
+// their string representation. A path sorts before any path it is a prefix
+// of. Tags are ignored.
+func Compare(a, b ContextPath) int {
+	for i := 0; i < a.Len() && i < b.Len(); i++ {
+		if c := compareElement(a.Path[i], b.Path[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a.Len() < b.Len():
+		return -1
+	case a.Len() > b.Len():
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareElement(a, b interface{}) int {
+	ai, aIsInt := a.(int)
+	bi, bIsInt := b.(int)
+	switch {
+	case aIsInt && bIsInt:
+		switch {
+		case ai < bi:
+			return -1
+		case ai > bi:
+			return 1
+		}
+		return 0
+	case aIsInt:
+		return -1
+	case bIsInt:
+		return 1
+	}
+	if c := strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)); c != 0 {
+		return c
+	}
+	// e.g. distinguish tree.Key("foo") from "foo"
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+// HasPrefix returns true if the first elements of c are the elements of
+// prefix. Every path has the empty path as a prefix. Tags are ignored.
+func (c ContextPath) HasPrefix(prefix ContextPath) bool {
+	if prefix.Len() > c.Len() {
+		return false
+	}
+	for i, e := range prefix.Path {
+		if compareElement(c.Path[i], e) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Pattern matches ContextPaths against a pattern in the same format as
+// ContextPath.String(), e.g. "$.storage.files.*.path". In a pattern, "*"
+// matches any single element and "**" matches zero or more elements. Other
+// elements must equal the string representation of the corresponding path
+// element.
+type Pattern struct {
+	elems []string
+}
+
+// ParsePattern parses a Pattern. It returns ErrBadPattern if s does not
+// start with "$".
+func ParsePattern(s string) (Pattern, error) {
+	elems := strings.Split(s, ".")
+	if elems[0] != "$" {
+		return Pattern{}, ErrBadPattern
+	}
+	return Pattern{elems: elems[1:]}, nil
+}
+
+// MustParsePattern is like ParsePattern but panics if s is invalid.
+func MustParsePattern(s string) Pattern {
+	p, err := ParsePattern(s)
+	if err != nil {
+		panic(fmt.Sprintf("%s: %q", err, s))
+	}
+	return p
+}
+
+func (p Pattern) String() string {
+	return strings.Join(append([]string{"$"}, p.elems...), ".")
+}
+
+// Match returns true if c matches the pattern.
+func (p Pattern) Match(c ContextPath) bool {
+	return matchElems(p.elems, c.Path)
+}
+
+func matchElems(pattern []string, elems []interface{}) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(elems); i++ {
+				if matchElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(elems) == 0 {
+				return false
+			}
+		default:
+			if len(elems) == 0 || fmt.Sprintf("%v", elems[0]) != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		elems = elems[1:]
+	}
+	return len(elems) == 0
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package path
+
+import (
+	"testing"
+)
+
+type key string
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a   ContextPath
+		b   ContextPath
+		out int
+	}{
+		{New(""), New(""), 0},
+		{New("json"), New("yaml"), 0},
+		{New("", "a"), New("", "a"), 0},
+		{New("", "a"), New("", "b"), -1},
+		{New("", "a", "b"), New("", "a"), 1},
+		{New("", 2), New("", 10), -1},
+		{New("", 10), New("", "a"), -1},
+		{New("", "0"), New("", 0), 1},
+		{New("", "a"), New("", key("a")), 1},
+	}
+
+	for i, test := range tests {
+		if c := Compare(test.a, test.b); c != test.out {
+			t.Errorf("#%d: expected %d, got %d", i, test.out, c)
+		}
+		if c := Compare(test.b, test.a); c != -test.out {
+			t.Errorf("#%d: expected %d reversed, got %d", i, -test.out, c)
+		}
+		if test.a.Equal(test.b) != (test.out == 0) {
+			t.Errorf("#%d: Equal disagrees with Compare", i)
+		}
+	}
+}
+
+func TestHasPrefix(t *testing.T) {
+	tests := []struct {
+		c      ContextPath
+		prefix ContextPath
+		out    bool
+	}{
+		{New(""), New(""), true},
+		{New("", "a", 1), New(""), true},
+		{New("", "a", 1), New("json", "a"), true},
+		{New("", "a", 1), New("", "a", 1), true},
+		{New("", "a"), New("", "a", 1), false},
+		{New("", "a", 1), New("", "a", "1"), false},
+		{New("", "ab"), New("", "a"), false},
+	}
+
+	for i, test := range tests {
+		if out := test.c.HasPrefix(test.prefix); out != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+		}
+	}
+}
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		c       ContextPath
+		out     bool
+	}{
+		{"$", New(""), true},
+		{"$", New("", "a"), false},
+		{"$.a", New("", "a"), true},
+		{"$.a", New("", "b"), false},
+		{"$.a.*.c", New("", "a", 4, "c"), true},
+		{"$.a.*.c", New("", "a", "c"), false},
+		{"$.a.4", New("", "a", 4), true},
+		{"$.a.**", New("", "a"), true},
+		{"$.a.**", New("", "a", 1, "b"), true},
+		{"$.**.path", New("", "storage", "files", 0, "path"), true},
+		{"$.**.path", New("", "storage", "files", 0, "mode"), false},
+		{"$.**", New(""), true},
+	}
+
+	for i, test := range tests {
+		p, err := ParsePattern(test.pattern)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p.String() != test.pattern {
+			t.Errorf("#%d: pattern did not round trip: %q", i, p.String())
+		}
+		if out := p.Match(test.c); out != test.out {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, out)
+		}
+	}
+
+	if _, err := ParsePattern("a.b"); err != ErrBadPattern {
+		t.Errorf("expected ErrBadPattern, got %v", err)
+	}
+}
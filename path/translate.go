@@ -0,0 +1,157 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package path
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Translation records that the value at From in a source document produced
+// the value at To in a generated document.
+type Translation struct {
+	From ContextPath
+	To   ContextPath
+}
+
+func (t Translation) String() string {
+	return fmt.Sprintf("%s -> %s", t.From, t.To)
+}
+
+// TranslationSet is a set of Translations from paths in a source document
+// to paths in a document generated from it. It is used to map paths in the
+// generated document back to the source, e.g. to report errors found while
+// validating the generated document against the source. A translation for
+// a path also covers every path under it that has no more specific
+// translation.
+//
+// TranslationSets must be created with NewTranslationSet. The methods that
+// modify a set do so in place.
+type TranslationSet struct {
+	FromTag string
+	ToTag   string
+	set     map[string]Translation
+}
+
+// NewTranslationSet returns an empty TranslationSet. fromTag and toTag are
+// the tags of the source and generated documents.
+func NewTranslationSet(fromTag, toTag string) TranslationSet {
+	return TranslationSet{
+		FromTag: fromTag,
+		ToTag:   toTag,
+		set:     map[string]Translation{},
+	}
+}
+
+// pathKey uniquely identifies a path, distinguishing e.g. 0 from "0". Nil
+// and empty paths have the same key.
+func pathKey(c ContextPath) string {
+	var b strings.Builder
+	for _, e := range c.Path {
+		fmt.Fprintf(&b, "%T:%#v,", e, e)
+	}
+	return b.String()
+}
+
+// AddTranslation records that from produced to, replacing any existing
+// translation for to.
+func (ts TranslationSet) AddTranslation(from, to ContextPath) {
+	from = from.Copy()
+	from.Tag = ts.FromTag
+	to = to.Copy()
+	to.Tag = ts.ToTag
+	ts.set[pathKey(to)] = Translation{
+		From: from,
+		To:   to,
+	}
+}
+
+// AddIdentity records that each of the given paths in the generated
+// document came from the same path in the source.
+func (ts TranslationSet) AddIdentity(paths ...ContextPath) {
+	for _, p := range paths {
+		ts.AddTranslation(p, p)
+	}
+}
+
+// Merge adds all the translations in from to ts, replacing existing
+// translations for the same paths.
+func (ts TranslationSet) Merge(from TranslationSet) {
+	for _, t := range from.set {
+		ts.AddTranslation(t.From, t.To)
+	}
+}
+
+// Prefix returns a new TranslationSet with from prepended to the source
+// path and to prepended to the generated path of every translation in ts.
+// It is used when a translated subtree is placed inside a larger document.
+func (ts TranslationSet) Prefix(from, to ContextPath) TranslationSet {
+	ret := NewTranslationSet(ts.FromTag, ts.ToTag)
+	for _, t := range ts.set {
+		ret.AddTranslation(from.Copy().Append(t.From.Path...), to.Copy().Append(t.To.Path...))
+	}
+	return ret
+}
+
+// Compose returns a TranslationSet mapping from the source of earlier to
+// the generated document of ts, where earlier's generated document is ts's
+// source. Translations in ts whose source earlier cannot translate are
+// dropped.
+func (ts TranslationSet) Compose(earlier TranslationSet) TranslationSet {
+	ret := NewTranslationSet(earlier.FromTag, ts.ToTag)
+	for _, t := range ts.set {
+		if from, ok := earlier.Translate(t.From); ok {
+			ret.AddTranslation(from, t.To)
+		}
+	}
+	return ret
+}
+
+// Translate maps c, a path in the generated document, back to the source.
+// It uses the translation for the longest prefix of c, appending the rest
+// of c to the translated path. It returns false if no prefix of c has a
+// translation.
+func (ts TranslationSet) Translate(c ContextPath) (ContextPath, bool) {
+	for i := c.Len(); i >= 0; i-- {
+		prefix := ContextPath{Path: c.Path[:i]}
+		if t, ok := ts.set[pathKey(prefix)]; ok {
+			ret := t.From.Copy().Append(c.Path[i:]...)
+			ret.Tag = ts.FromTag
+			return ret, true
+		}
+	}
+	return c, false
+}
+
+// Translations returns the translations in ts, ordered by generated path.
+func (ts TranslationSet) Translations() []Translation {
+	ret := make([]Translation, 0, len(ts.set))
+	for _, t := range ts.set {
+		ret = append(ret, t)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return Compare(ret[i].To, ret[j].To) < 0
+	})
+	return ret
+}
+
+func (ts TranslationSet) String() string {
+	str := fmt.Sprintf("from %q to %q:\n", ts.FromTag, ts.ToTag)
+	for _, t := range ts.Translations() {
+		str += "  " + t.String() + "\n"
+	}
+	return str
+}
@@ -0,0 +1,151 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package path
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	ts := NewTranslationSet("yaml", "json")
+	ts.AddTranslation(New("", "storage", "trees", 0), New("", "storage", "files", 0))
+	ts.AddTranslation(New("", "storage", "trees", 0, "local"), New("", "storage", "files", 0, "contents", "source"))
+	ts.AddIdentity(New("", "passwd"))
+
+	tests := []struct {
+		in  ContextPath
+		out ContextPath
+		ok  bool
+	}{
+		{
+			in:  New("json", "storage", "files", 0),
+			out: New("yaml", "storage", "trees", 0),
+			ok:  true,
+		},
+		// most specific translation wins
+		{
+			in:  New("json", "storage", "files", 0, "contents", "source"),
+			out: New("yaml", "storage", "trees", 0, "local"),
+			ok:  true,
+		},
+		// descendants of a translated path are translated too
+		{
+			in:  New("json", "storage", "files", 0, "mode"),
+			out: New("yaml", "storage", "trees", 0, "mode"),
+			ok:  true,
+		},
+		{
+			in:  New("json", "passwd", "users", 3, "name"),
+			out: New("yaml", "passwd", "users", 3, "name"),
+			ok:  true,
+		},
+		// ints and strings are distinct
+		{
+			in:  New("json", "storage", "files", "0"),
+			out: New("json", "storage", "files", "0"),
+		},
+		{
+			in:  New("json", "ignition"),
+			out: New("json", "ignition"),
+		},
+	}
+
+	for i, test := range tests {
+		out, ok := ts.Translate(test.in)
+		if ok != test.ok || !reflect.DeepEqual(out, test.out) {
+			t.Errorf("#%d: expected %v %v, got %v %v", i, test.out, test.ok, out, ok)
+		}
+	}
+
+	// a translation of the root covers every path, however it's written
+	root := NewTranslationSet("yaml", "json")
+	root.AddIdentity(New(""))
+	root.AddTranslation(New("", "a"), New("", "b", "x"))
+	tests = []struct {
+		in  ContextPath
+		out ContextPath
+		ok  bool
+	}{
+		{
+			in:  New("json"),
+			out: New("yaml"),
+			ok:  true,
+		},
+		{
+			in:  ContextPath{Path: []interface{}{}, Tag: "json"},
+			out: New("yaml"),
+			ok:  true,
+		},
+		{
+			in:  New("json", "b", "y", 1),
+			out: New("yaml", "b", "y", 1),
+			ok:  true,
+		},
+		{
+			in:  New("json", "b", "x", 1),
+			out: New("yaml", "a", 1),
+			ok:  true,
+		},
+	}
+	for i, test := range tests {
+		out, ok := root.Translate(test.in)
+		if ok != test.ok || !reflect.DeepEqual(out, test.out) {
+			t.Errorf("root #%d: expected %v %v, got %v %v", i, test.out, test.ok, out, ok)
+		}
+	}
+}
+
+func TestTranslationSetOperations(t *testing.T) {
+	// a subtree translated on its own, then placed into a document
+	sub := NewTranslationSet("yaml", "json")
+	sub.AddTranslation(New("", "local"), New("", "contents", "source"))
+	prefixed := sub.Prefix(New("", "trees", 2), New("", "files", 5))
+	out, ok := prefixed.Translate(New("", "files", 5, "contents", "source"))
+	if !ok || !out.Equal(New("", "trees", 2, "local")) {
+		t.Errorf("prefix: got %v %v", out, ok)
+	}
+
+	// a subtree translated as a whole, then placed into a document
+	whole := NewTranslationSet("yaml", "json")
+	whole.AddIdentity(New(""))
+	out, ok = whole.Prefix(New("", "trees", 2), New("", "files", 5)).Translate(New("", "files", 5, "mode"))
+	if !ok || !out.Equal(New("", "trees", 2, "mode")) {
+		t.Errorf("prefix root: got %v %v", out, ok)
+	}
+
+	// merging
+	merged := NewTranslationSet("yaml", "json")
+	merged.AddIdentity(New("", "ignition"))
+	merged.Merge(prefixed)
+	if len(merged.Translations()) != 2 {
+		t.Errorf("merge: expected 2 translations, got %v", merged)
+	}
+
+	// chaining source -> intermediate -> output
+	first := NewTranslationSet("src", "mid")
+	first.AddTranslation(New("", "a"), New("", "b"))
+	second := NewTranslationSet("mid", "out")
+	second.AddTranslation(New("", "b", "x"), New("", "c"))
+	second.AddTranslation(New("", "unknown"), New("", "d"))
+	composed := second.Compose(first)
+	out, ok = composed.Translate(New("out", "c", 1))
+	if !ok || !reflect.DeepEqual(out, New("src", "a", "x", 1)) {
+		t.Errorf("compose: got %v %v", out, ok)
+	}
+	if _, ok := composed.Translate(New("out", "d")); ok {
+		t.Errorf("compose: kept a translation with no source")
+	}
+}
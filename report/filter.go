@@ -0,0 +1,83 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"github.com/coreos/vcontext/path"
+)
+
+// Filter returns a new Report containing the entries for which keep returns
+// true. It is incomplete if r is. r is not modified.
+func (r Report) Filter(keep func(Entry) bool) Report {
+	ret := Report{Incomplete: r.Incomplete}
+	for _, e := range r.Entries {
+		if keep(e) {
+			ret.Entries = append(ret.Entries, e)
+		}
+	}
+	return ret
+}
+
+// FilterKind returns a new Report containing only the entries with one of
+// the given kinds.
+func (r Report) FilterKind(kinds ...EntryKind) Report {
+	return r.Filter(func(e Entry) bool {
+		for _, k := range kinds {
+			if e.Kind == k {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FilterPath returns a new Report containing only the entries whose context
+// is prefix or is under prefix.
+func (r Report) FilterPath(prefix path.ContextPath) Report {
+	return r.Filter(func(e Entry) bool {
+		return e.Context.HasPrefix(prefix)
+	})
+}
+
+// FilterPattern returns a new Report containing only the entries whose
+// context matches p.
+func (r Report) FilterPattern(p path.Pattern) Report {
+	return r.Filter(func(e Entry) bool {
+		return p.Match(e.Context)
+	})
+}
+
+// Map returns a new Report containing the result of calling f on each
+// entry. r is not modified.
+func (r Report) Map(f func(Entry) Entry) Report {
+	ret := Report{Incomplete: r.Incomplete}
+	for _, e := range r.Entries {
+		ret.Entries = append(ret.Entries, f(e))
+	}
+	return ret
+}
+
+// RemapKind returns a new Report in which entries of kind from whose
+// context is under prefix have kind to instead. Use an empty prefix to
+// remap the whole report, e.g. r.RemapKind(Warn, Error, path.ContextPath{})
+// treats warnings as errors.
+func (r Report) RemapKind(from, to EntryKind, prefix path.ContextPath) Report {
+	return r.Map(func(e Entry) Entry {
+		if e.Kind == from && e.Context.HasPrefix(prefix) {
+			e.Kind = to
+		}
+		return e
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+)
+
+func testReport() Report {
+	return Report{
+		Entries: []Entry{
+			entryAt(Error, "1", 0, 0, "storage", "files", 0, "path"),
+			entryAt(Warn, "2", 0, 0, "storage", "files", 1, "mode"),
+			entryAt(Warn, "3", 0, 0, "passwd", "users", 0),
+			entryAt(Info, "4", 0, 0, "storage"),
+			entryAt(Error, "5", 0, 0),
+		},
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		out Report
+		msg []string
+	}{
+		{testReport().FilterKind(Error), []string{"1", "5"}},
+		{testReport().FilterKind(Warn, Info), []string{"2", "3", "4"}},
+		{testReport().FilterPath(path.New("", "storage")), []string{"1", "2", "4"}},
+		{testReport().FilterPath(path.New("", "storage", "files", 1)), []string{"2"}},
+		{testReport().FilterPath(path.New("")), []string{"1", "2", "3", "4", "5"}},
+		{testReport().FilterPattern(path.MustParsePattern("$.storage.files.*.path")), []string{"1"}},
+		{testReport().FilterPattern(path.MustParsePattern("$.**.0")), []string{"3"}},
+		{testReport().FilterPath(path.New("", "storage")).FilterKind(Warn), []string{"2"}},
+	}
+
+	for i, test := range tests {
+		if !reflect.DeepEqual(messages(test.out), test.msg) {
+			t.Errorf("#%d: expected %v, got %v", i, test.msg, messages(test.out))
+		}
+	}
+}
+
+func TestRemapKind(t *testing.T) {
+	orig := testReport()
+
+	strict := orig.RemapKind(Warn, Error, path.ContextPath{})
+	if len(strict.FilterKind(Warn).Entries) != 0 || len(strict.FilterKind(Error).Entries) != 4 {
+		t.Errorf("warnings were not promoted: %v", strict)
+	}
+
+	lenient := orig.RemapKind(Error, Warn, path.New("", "storage"))
+	if !reflect.DeepEqual(messages(lenient.FilterKind(Error)), []string{"5"}) {
+		t.Errorf("errors under $.storage were not demoted: %v", lenient)
+	}
+
+	// the original must be untouched
+	if !reflect.DeepEqual(orig, testReport()) {
+		t.Errorf("original report was modified: %v", orig)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+// Fix is a machine-applicable fix for the problem an entry describes. It is
+// expressed as path-level edits of the document (Patch), as edits of its
+// source text (Edits), or both, in which case the text edits are applied
+// first. The fix package applies fixes.
+type Fix struct {
+	Description string     `json:",omitempty"`
+	Patch       []PatchOp  `json:",omitempty"`
+	Edits       []TextEdit `json:",omitempty"`
+}
+
+// PatchOp operations, as in JSON Patch (RFC 6902)
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+)
+
+// PatchOp is a JSON Patch style edit of the value at Path.
+type PatchOp struct {
+	// Op is one of OpAdd, OpRemove, OpReplace or OpMove. Adding to a slice
+	// index inserts before it; adding to a map key sets it.
+	Op   string
+	Path path.ContextPath
+	// From is the path moved from by OpMove.
+	From path.ContextPath
+	// Value is the value added by OpAdd or OpReplace, as decoded by
+	// encoding/json into an interface{}.
+	Value interface{} `json:",omitempty"`
+}
+
+// TextEdit replaces a span of the source text with NewText. The span runs
+// from Marker.StartP through Marker.EndP inclusive. If EndP is nil, NewText
+// is inserted before StartP. The Index of both positions must be set.
+//
+// If Path is not empty, Correlate sets Marker to span the whole text of the
+// node at Path, including quotes and brackets, so the edit replaces that
+// node, or its key if Path ends with a tree.Key. If there is no such node
+// or its end is unknown, e.g. for yaml maps, Marker is cleared so the edit
+// fails to apply instead of inserting text.
+type TextEdit struct {
+	Marker  tree.Marker
+	Path    path.ContextPath
+	NewText string
+}
+
+// Fixer is implemented by errors which know how to fix themselves. AddOn
+// records the fixes of any error in err's chain implementing it.
+type Fixer interface {
+	Fixes() []Fix
+}
+
+type fixError struct {
+	err   error
+	fixes []Fix
+}
+
+// WithFixes returns an error wrapping err and implementing Fixer.
+func WithFixes(err error, fixes ...Fix) error {
+	return fixError{
+		err:   err,
+		fixes: fixes,
+	}
+}
+
+func (e fixError) Error() string {
+	return e.err.Error()
+}
+
+func (e fixError) Unwrap() error {
+	return e.err
+}
+
+func (e fixError) Fixes() []Fix {
+	return e.fixes
+}
+
+// Fixes returns the fixes of all the entries in r.
+func (r Report) Fixes() []Fix {
+	var ret []Fix
+	for _, e := range r.Entries {
+		ret = append(ret, e.Fixes...)
+	}
+	return ret
+}
+
+// editMarker returns a marker spanning the whole text of the node at c in
+// n, for an edit replacing it. Edits must not fall back to a parent node
+// like entries do. The json package's markers of maps and slices start
+// after the opening bracket, and those of keys end at the byte following
+// the closing quote; yaml markers span scalars exactly and have no end for
+// other nodes.
+func editMarker(n tree.Node, c path.ContextPath) (tree.Marker, bool) {
+	child, err := n.Get(c)
+	if err != nil {
+		return tree.Marker{}, false
+	}
+	m := child.GetMarker()
+	if m.StartP == nil || m.EndP == nil {
+		return tree.Marker{}, false
+	}
+	switch v := child.(type) {
+	case tree.MapNode, tree.SliceNode:
+		m.StartP = shiftPos(m.StartP, -1)
+	case tree.Leaf:
+		// unlike json's, yaml keys have a tag
+		if _, ok := c.Path[c.Len()-1].(tree.Key); ok && v.Format.Tag == "" {
+			m.EndP = shiftPos(m.EndP, -1)
+		}
+	}
+	return m, true
+}
+
+// shiftPos returns a copy of p moved by n single-byte characters on its
+// line.
+func shiftPos(p *tree.Pos, n int64) *tree.Pos {
+	ret := *p
+	ret.Index += n
+	ret.Column += n
+	return &ret
+}
+
+// mapEdits returns a copy of fixes with their text edits replaced by the
+// result of calling f on them. fixes is not modified.
+func mapEdits(fixes []Fix, f func(TextEdit) TextEdit) []Fix {
+	if len(fixes) == 0 {
+		return fixes
+	}
+	ret := make([]Fix, 0, len(fixes))
+	for _, fix := range fixes {
+		if len(fix.Edits) > 0 {
+			edits := make([]TextEdit, 0, len(fix.Edits))
+			for _, e := range fix.Edits {
+				edits = append(edits, f(e))
+			}
+			fix.Edits = edits
+		}
+		ret = append(ret, fix)
+	}
+	return ret
+}
+
+// mapFixPaths returns a copy of fixes with the non-empty paths of their
+// patch operations and text edits replaced by the result of calling f on
+// them. fixes is not modified.
+func mapFixPaths(fixes []Fix, f func(path.ContextPath) path.ContextPath) []Fix {
+	mapPath := func(c path.ContextPath) path.ContextPath {
+		if c.Len() == 0 {
+			return c
+		}
+		return f(c)
+	}
+	ret := mapEdits(fixes, func(te TextEdit) TextEdit {
+		te.Path = mapPath(te.Path)
+		return te
+	})
+	for i, fix := range ret {
+		if len(fix.Patch) == 0 {
+			continue
+		}
+		ops := make([]PatchOp, 0, len(fix.Patch))
+		for _, op := range fix.Patch {
+			op.Path = mapPath(op.Path)
+			op.From = mapPath(op.From)
+			ops = append(ops, op)
+		}
+		ret[i].Patch = ops
+	}
+	return ret
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"fmt"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+// Related is another location involved in the problem an entry describes,
+// e.g. the other filesystem a mount point conflicts with. Correlate,
+// Translate and CorrelateProvenance update related locations like they do
+// the entry's own.
+type Related struct {
+	Context path.ContextPath
+	Marker  tree.Marker
+	// Note says how the location is involved, e.g. "conflicting filesystem".
+	Note string `json:",omitempty"`
+}
+
+func (r Related) String() string {
+	loc, at := location(r.Context, r.Marker)
+	note := ""
+	if r.Note != "" {
+		note = ": " + r.Note
+	}
+	return fmt.Sprintf("%srelated%s%s", loc, at, note)
+}
+
+// Relater is implemented by errors which involve other locations than the
+// one they are reported at. AddOn records the related locations of any
+// error in err's chain implementing it.
+type Relater interface {
+	Related() []Related
+}
+
+type relatedError struct {
+	err     error
+	related []Related
+}
+
+// WithRelated returns an error wrapping err and implementing Relater.
+func WithRelated(err error, related ...Related) error {
+	return relatedError{
+		err:     err,
+		related: related,
+	}
+}
+
+func (e relatedError) Error() string {
+	return e.err.Error()
+}
+
+func (e relatedError) Unwrap() error {
+	return e.err
+}
+
+func (e relatedError) Related() []Related {
+	return e.related
+}
+
+// mapRelated returns a copy of rs with f applied to each location. Reports
+// returned by Filter share their entries' related locations, so they must
+// not be modified in place.
+func mapRelated(rs []Related, f func(Related) Related) []Related {
+	if len(rs) == 0 {
+		return rs
+	}
+	ret := make([]Related, 0, len(rs))
+	for _, r := range rs {
+		ret = append(ret, f(r))
+	}
+	return ret
+}
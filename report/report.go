@@ -15,6 +15,7 @@
 package report
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/coreos/vcontext/path"
@@ -30,11 +31,17 @@ type EntryKind interface {
 // Report is a collection of information from validating a struct.
 type Report struct {
 	Entries []Entry
+
+	// Incomplete is set if validation stopped before checking everything,
+	// e.g. because it was canceled, so a lack of errors means nothing.
+	Incomplete bool `json:",omitempty"`
 }
 
-// Merge adds the entries from child to r.
+// Merge adds the entries from child to r. r is incomplete if either report
+// is.
 func (r *Report) Merge(child Report) {
 	r.Entries = append(r.Entries, child.Entries...)
+	r.Incomplete = r.Incomplete || child.Incomplete
 }
 
 // getDeepestNode returns the deepest node matching the context.
@@ -47,10 +54,70 @@ func getDeepestNode(n tree.Node, c path.ContextPath) tree.Node {
 }
 
 // Correlate takes a node tree and populates the markers in the report's entries
-// based on the entries' context.
+// based on the entries' context. It also resolves the markers of text edits
+// with a Path.
 func (r *Report) Correlate(n tree.Node) {
 	for i, e := range r.Entries {
 		r.Entries[i].Marker = getDeepestNode(n, e.Context).GetMarker()
+		r.Entries[i].Related = mapRelated(e.Related, func(rel Related) Related {
+			rel.Marker = getDeepestNode(n, rel.Context).GetMarker()
+			return rel
+		})
+		r.Entries[i].Fixes = mapEdits(e.Fixes, func(te TextEdit) TextEdit {
+			if te.Path.Len() == 0 {
+				return te
+			}
+			te.Marker, _ = editMarker(n, te.Path)
+			return te
+		})
+	}
+}
+
+// Translate rewrites the context of each entry from a path in a generated
+// document to the path in the source document it came from, using ts. It
+// should be called before Correlate when correlating against the source.
+// The paths of related locations and fixes are rewritten too. Paths with
+// no translation are left unchanged.
+func (r *Report) Translate(ts path.TranslationSet) {
+	translate := func(c path.ContextPath) path.ContextPath {
+		if t, ok := ts.Translate(c); ok {
+			return t
+		}
+		return c
+	}
+	for i, e := range r.Entries {
+		r.Entries[i].Context = translate(e.Context)
+		r.Entries[i].Related = mapRelated(e.Related, func(rel Related) Related {
+			rel.Context = translate(rel.Context)
+			return rel
+		})
+		r.Entries[i].Fixes = mapFixPaths(e.Fixes, translate)
+	}
+}
+
+// CorrelateProvenance is like Correlate, but for documents merged from
+// several sources. It populates each entry's marker, including the name of
+// the source document, from the origin p records for the entry's context.
+// Entries with no recorded origin are left unchanged. The markers of text
+// edits with a Path are cleared, since p can't tell where a node's text
+// ends; resolve them with Correlate against the document they edit.
+func (r *Report) CorrelateProvenance(p *tree.Provenance) {
+	for i, e := range r.Entries {
+		if m, ok := p.Lookup(e.Context); ok {
+			r.Entries[i].Marker = m
+		}
+		r.Entries[i].Related = mapRelated(e.Related, func(rel Related) Related {
+			if m, ok := p.Lookup(rel.Context); ok {
+				rel.Marker = m
+			}
+			return rel
+		})
+		r.Entries[i].Fixes = mapEdits(e.Fixes, func(te TextEdit) TextEdit {
+			if te.Path.Len() != 0 {
+				te.Marker = tree.Marker{}
+			}
+			return te
+		})
 	}
 }
 
@@ -64,6 +131,42 @@ func (r Report) IsFatal() bool {
 	return false
 }
 
+// Err returns r as an error if it contains any fatal entries, and nil
+// otherwise. The returned error is a *Report; use errors.As or FromError to
+// recover it.
+func (r Report) Err() error {
+	if !r.IsFatal() {
+		return nil
+	}
+	return &r
+}
+
+// ErrAt returns r as an error if it contains any entries at least as severe
+// as k, and nil otherwise. For example, ErrAt(Warn) treats warnings as
+// errors. Entry kinds other than Kind are treated as Error if they are
+// fatal and Info if they are not. The returned error is a *Report.
+func (r Report) ErrAt(k Kind) error {
+	for _, e := range r.Entries {
+		if severity(e.Kind) <= k {
+			return &r
+		}
+	}
+	return nil
+}
+
+// FromError returns the Report in err's chain, if any.
+func FromError(err error) (Report, bool) {
+	var rp *Report
+	if errors.As(err, &rp) && rp != nil {
+		return *rp, true
+	}
+	var r Report
+	if errors.As(err, &r) {
+		return r, true
+	}
+	return Report{}, false
+}
+
 func (r Report) String() string {
 	str := ""
 	for _, e := range r.Entries {
@@ -72,6 +175,21 @@ func (r Report) String() string {
 	return str
 }
 
+// Error implements the error interface. It returns the same text as String.
+func (r Report) Error() string {
+	return r.String()
+}
+
+// Unwrap returns the report's entries as errors, so errors.Is and errors.As
+// can find the errors they were created from.
+func (r Report) Unwrap() []error {
+	errs := make([]error, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		errs = append(errs, e)
+	}
+	return errs
+}
+
 // Entry represents one error or message from validation.
 type Entry struct {
 	// Kind is the severity of the message.
@@ -83,20 +201,73 @@ type Entry struct {
 
 	// Marker is the literal location in a json or yaml blob of the error.
 	Marker tree.Marker
+
+	// Code is an optional stable, machine-readable identifier for the
+	// diagnostic (e.g. "VC0012"). Unlike Message, it does not change
+	// between releases, so it is suitable for filtering and suppression.
+	Code string `json:",omitempty"`
+
+	// HelpURL optionally links to documentation about the diagnostic.
+	HelpURL string `json:",omitempty"`
+
+	// Fixes are machine-applicable fixes for the problem.
+	Fixes []Fix `json:",omitempty"`
+
+	// Related are other locations involved in the problem.
+	Related []Related `json:",omitempty"`
+
+	// err is the error the entry was created from, if any.
+	err error
+}
+
+// Error implements the error interface. It returns the same text as String.
+func (e Entry) Error() string {
+	return e.String()
 }
 
+// Unwrap returns the error the entry was created from, or nil if the entry
+// was not created by one of the AddOn functions.
+func (e Entry) Unwrap() error {
+	return e.err
+}
+
+// String returns the entry as a line of text, followed by an indented
+// line for each related location.
 func (e Entry) String() string {
-	at := ""
-	switch {
-	case e.Marker.StartP != nil && e.Context.Len() != 0:
-		at = fmt.Sprintf(" at %s, %s", e.Context.String(), e.Marker.String())
-	case e.Marker.StartP != nil:
-		at = fmt.Sprintf(" at %s", e.Marker.String())
-	case e.Context.Len() != 0:
-		at = fmt.Sprintf(" at %s", e.Context.String())
+	loc, at := location(e.Context, e.Marker)
+	kind := e.Kind.String()
+	if e.Code != "" {
+		kind = fmt.Sprintf("%s[%s]", kind, e.Code)
+	}
+	help := ""
+	if e.HelpURL != "" {
+		help = fmt.Sprintf(" (see %s)", e.HelpURL)
+	}
+
+	str := fmt.Sprintf("%s%s%s: %s%s", loc, kind, at, e.Message, help)
+	for _, rel := range e.Related {
+		str += "\n\t" + rel.String()
 	}
+	return str
+}
 
-	return fmt.Sprintf("%s%s: %s", e.Kind.String(), at, e.Message)
+// location formats c and m for String. If we know the file, lead with
+// file:line:col like a compiler so editors and terminals can link to it.
+func location(c path.ContextPath, m tree.Marker) (loc, at string) {
+	switch {
+	case m.StartP != nil && m.Source != "":
+		loc = m.String() + ": "
+		if c.Len() != 0 {
+			at = fmt.Sprintf(" at %s", c.String())
+		}
+	case m.StartP != nil && c.Len() != 0:
+		at = fmt.Sprintf(" at %s, %s", c.String(), m.String())
+	case m.StartP != nil:
+		at = fmt.Sprintf(" at %s", m.String())
+	case c.Len() != 0:
+		at = fmt.Sprintf(" at %s", c.String())
+	}
+	return loc, at
 }
 
 // Kind is a default set of EntryKind.
@@ -125,14 +296,114 @@ func (k Kind) IsFatal() bool {
 	return k == Error
 }
 
+// severity maps any EntryKind onto a Kind so kinds can be ordered. Lower
+// values are more severe.
+func severity(k EntryKind) Kind {
+	if kind, ok := k.(Kind); ok {
+		return kind
+	}
+	if k.IsFatal() {
+		return Error
+	}
+	return Info
+}
+
+// Coder is implemented by errors which carry a stable diagnostic code.
+// AddOn records the code of any error in err's chain implementing it.
+type Coder interface {
+	Code() string
+}
+
+// HelpURLer is implemented by errors which link to documentation about
+// themselves. AddOn records the URL of any error in err's chain
+// implementing it.
+type HelpURLer interface {
+	HelpURL() string
+}
+
+// CodedError is an error annotated with a diagnostic code and help URL.
+// It implements Coder and HelpURLer.
+type CodedError struct {
+	err  error
+	code string
+	url  string
+}
+
+// NewCodedError wraps err with the given code and help URL. helpURL may be
+// empty.
+func NewCodedError(err error, code, helpURL string) CodedError {
+	return CodedError{
+		err:  err,
+		code: code,
+		url:  helpURL,
+	}
+}
+
+func (e CodedError) Error() string {
+	return e.err.Error()
+}
+
+func (e CodedError) Unwrap() error {
+	return e.err
+}
+
+func (e CodedError) Code() string {
+	return e.code
+}
+
+func (e CodedError) HelpURL() string {
+	return e.url
+}
+
+// AddOn adds err to report with kind k if err is not nil. If err (or an
+// error it wraps) implements Coder, HelpURLer, Fixer or Relater, the code,
+// help URL, fixes and related locations are recorded on the entry.
 func (r *Report) AddOn(c path.ContextPath, err error, k EntryKind) {
+	r.AddOnWithCode(c, err, k, "", "")
+}
+
+// AddOnWithCode adds err to report with kind k and the given diagnostic
+// code and help URL if err is not nil. Empty code or helpURL fall back to
+// those carried by err, if any.
+func (r *Report) AddOnWithCode(c path.ContextPath, err error, k EntryKind, code, helpURL string) {
 	if err == nil {
 		return
 	}
+	if code == "" {
+		var coder Coder
+		if errors.As(err, &coder) {
+			code = coder.Code()
+		}
+	}
+	if helpURL == "" {
+		var helper HelpURLer
+		if errors.As(err, &helper) {
+			helpURL = helper.HelpURL()
+		}
+	}
+	var fixes []Fix
+	var fixer Fixer
+	if errors.As(err, &fixer) {
+		fixes = fixer.Fixes()
+	}
+	var related []Related
+	var relater Relater
+	if errors.As(err, &relater) {
+		// copy so Correlate doesn't modify the error's locations
+		related = append(related, relater.Related()...)
+		for i := range related {
+			related[i].Context = related[i].Context.Copy()
+		}
+	}
 	r.Entries = append(r.Entries, Entry{
 		Message: err.Error(),
 		Context: c.Copy(),
 		Kind:    k,
+		Code:    code,
+		HelpURL: helpURL,
+		Fixes:   fixes,
+		Related: related,
+		err:     err,
 	})
 }
 
@@ -151,3 +422,21 @@ func (r *Report) AddOnWarn(c path.ContextPath, err error) {
 func (r *Report) AddOnInfo(c path.ContextPath, err error) {
 	r.AddOn(c, err, Info)
 }
+
+// AddOnErrorWithCode adds err to report with kind "Error" and the given
+// code if err is not nil.
+func (r *Report) AddOnErrorWithCode(c path.ContextPath, err error, code string) {
+	r.AddOnWithCode(c, err, Error, code, "")
+}
+
+// AddOnWarnWithCode adds err to report with kind "Warning" and the given
+// code if err is not nil.
+func (r *Report) AddOnWarnWithCode(c path.ContextPath, err error, code string) {
+	r.AddOnWithCode(c, err, Warn, code, "")
+}
+
+// AddOnInfoWithCode adds err to report with kind "Info" and the given code
+// if err is not nil.
+func (r *Report) AddOnInfoWithCode(c path.ContextPath, err error, code string) {
+	r.AddOnWithCode(c, err, Info, code, "")
+}
@@ -0,0 +1,430 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+var (
+	errDummy = errors.New("dummy")
+)
+
+func TestAddOnCode(t *testing.T) {
+	tests := []struct {
+		add  func(r *Report)
+		code string
+		url  string
+		str  string
+	}{
+		{
+			add: func(r *Report) {
+				r.AddOnError(path.New("", "foo"), errDummy)
+			},
+			str: "error at $.foo: dummy",
+		},
+		{
+			add: func(r *Report) {
+				r.AddOnErrorWithCode(path.New("", "foo"), errDummy, "VC0001")
+			},
+			code: "VC0001",
+			str:  "error[VC0001] at $.foo: dummy",
+		},
+		{
+			add: func(r *Report) {
+				r.AddOnWarn(path.New(""), NewCodedError(errDummy, "VC0002", "https://example.com/VC0002"))
+			},
+			code: "VC0002",
+			url:  "https://example.com/VC0002",
+			str:  "warning[VC0002]: dummy (see https://example.com/VC0002)",
+		},
+		// codes are found through wrapping
+		{
+			add: func(r *Report) {
+				r.AddOnInfo(path.New(""), fmt.Errorf("wrapped: %w", NewCodedError(errDummy, "VC0003", "")))
+			},
+			code: "VC0003",
+			str:  "info[VC0003]: wrapped: dummy",
+		},
+		// explicit codes take precedence
+		{
+			add: func(r *Report) {
+				r.AddOnWithCode(path.New(""), NewCodedError(errDummy, "VC0004", "a"), Error, "VC0005", "")
+			},
+			code: "VC0005",
+			url:  "a",
+			str:  "error[VC0005]: dummy (see a)",
+		},
+	}
+
+	for i, test := range tests {
+		var r Report
+		test.add(&r)
+		if len(r.Entries) != 1 {
+			t.Fatalf("#%d: expected 1 entry, got %d", i, len(r.Entries))
+		}
+		e := r.Entries[0]
+		if e.Code != test.code || e.HelpURL != test.url {
+			t.Errorf("#%d: expected code %q url %q, got %q %q", i, test.code, test.url, e.Code, e.HelpURL)
+		}
+		if e.String() != test.str {
+			t.Errorf("#%d: expected %q, got %q", i, test.str, e.String())
+		}
+
+		// codes survive a round trip through json
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("#%d: marshaling: %v", i, err)
+		}
+		var out struct {
+			Entries []struct {
+				Code    string
+				HelpURL string
+			}
+		}
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("#%d: unmarshaling: %v", i, err)
+		}
+		if out.Entries[0].Code != test.code || out.Entries[0].HelpURL != test.url {
+			t.Errorf("#%d: json lost code or url: %s", i, b)
+		}
+	}
+}
+
+type testError struct {
+	field string
+}
+
+func (e testError) Error() string {
+	return "bad field " + e.field
+}
+
+func TestUnwrap(t *testing.T) {
+	var r Report
+	r.AddOnWarn(path.New("", "foo"), errors.New("unrelated"))
+	r.AddOnError(path.New("", "bar"), fmt.Errorf("wrapped: %w", errDummy))
+	r.AddOnError(path.New("", "baz"), testError{field: "baz"})
+
+	if !errors.Is(r.Entries[1], errDummy) {
+		t.Errorf("entry does not unwrap to its original error")
+	}
+	if errors.Is(r.Entries[0], errDummy) {
+		t.Errorf("entry unwraps to an unrelated error")
+	}
+	if !errors.Is(r, errDummy) {
+		t.Errorf("report does not unwrap to errDummy")
+	}
+	var te testError
+	if !errors.As(r, &te) || te.field != "baz" {
+		t.Errorf("report does not unwrap to testError, got %+v", te)
+	}
+	if (Entry{}).Unwrap() != nil {
+		t.Errorf("entry without error unwrapped to non-nil")
+	}
+	if r.Error() != r.String() {
+		t.Errorf("Error() and String() differ: %q vs %q", r.Error(), r.String())
+	}
+}
+
+func TestErr(t *testing.T) {
+	var warnings Report
+	warnings.AddOnWarn(path.New("", "foo"), errDummy)
+	warnings.AddOnInfo(path.New("", "bar"), errDummy)
+
+	var errs Report
+	errs.Merge(warnings)
+	errs.AddOnError(path.New("", "baz"), errDummy)
+
+	tests := []struct {
+		in    Report
+		err   bool
+		warn  bool
+		info  bool
+		fatal bool
+	}{
+		{
+			in: Report{},
+		},
+		{
+			in:   warnings,
+			warn: true,
+			info: true,
+		},
+		{
+			in:    errs,
+			err:   true,
+			warn:  true,
+			info:  true,
+			fatal: true,
+		},
+	}
+
+	for i, test := range tests {
+		for _, c := range []struct {
+			err      error
+			expected bool
+		}{
+			{test.in.Err(), test.fatal},
+			{test.in.ErrAt(Error), test.err},
+			{test.in.ErrAt(Warn), test.warn},
+			{test.in.ErrAt(Info), test.info},
+		} {
+			if (c.err != nil) != c.expected {
+				t.Errorf("#%d: expected error %v, got %v", i, c.expected, c.err)
+				continue
+			}
+			if c.err == nil {
+				continue
+			}
+			if c.err.Error() != test.in.String() {
+				t.Errorf("#%d: expected message %q, got %q", i, test.in.String(), c.err.Error())
+			}
+			wrapped := fmt.Errorf("validating: %w", c.err)
+			r, ok := FromError(wrapped)
+			if !ok || len(r.Entries) != len(test.in.Entries) {
+				t.Errorf("#%d: could not recover report from %v", i, wrapped)
+			}
+			if !errors.Is(wrapped, errDummy) {
+				t.Errorf("#%d: report error does not unwrap to its entries", i)
+			}
+		}
+	}
+
+	if _, ok := FromError(errDummy); ok {
+		t.Errorf("recovered a report from a plain error")
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	ts := path.NewTranslationSet("yaml", "json")
+	ts.AddTranslation(path.New("", "trees", 0), path.New("", "files", 2))
+
+	var r Report
+	r.AddOnError(path.New("json", "files", 2, "mode"), errDummy)
+	r.AddOnError(path.New("json", "ignition"), errDummy)
+	r.Translate(ts)
+
+	if c := r.Entries[0].Context; c.Tag != "yaml" || !c.Equal(path.New("", "trees", 0, "mode")) {
+		t.Errorf("entry was not translated: %v", c)
+	}
+	if c := r.Entries[1].Context; c.Tag != "json" || !c.Equal(path.New("", "ignition")) {
+		t.Errorf("untranslatable entry was modified: %v", c)
+	}
+
+	// fixes are translated too, without modifying the original
+	fix := Fix{
+		Patch: []PatchOp{
+			{Op: OpMove, From: path.New("json", "files", 2, "a"), Path: path.New("json", "files", 2, "b")},
+			{Op: OpRemove, Path: path.New("json", "ignition")},
+		},
+		Edits: []TextEdit{{Path: path.New("json", "files", 2, "mode"), NewText: "1"}},
+	}
+	r = Report{}
+	r.AddOnError(path.New("json", "files", 2), WithFixes(errDummy, fix))
+	r.Translate(ts)
+	expected := Fix{
+		Patch: []PatchOp{
+			{Op: OpMove, From: path.New("yaml", "trees", 0, "a"), Path: path.New("yaml", "trees", 0, "b")},
+			{Op: OpRemove, Path: path.New("json", "ignition")},
+		},
+		Edits: []TextEdit{{Path: path.New("yaml", "trees", 0, "mode"), NewText: "1"}},
+	}
+	if actual := r.Entries[0].Fixes; len(actual) != 1 || !reflect.DeepEqual(expected, actual[0]) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+	if c := fix.Patch[0].Path; c.Tag != "json" {
+		t.Errorf("original fix was modified: %v", c)
+	}
+}
+
+func TestCorrelateProvenance(t *testing.T) {
+	var p tree.Provenance
+	p.Record(path.New("", "foo"), "base.yaml", tree.Marker{
+		StartP: &tree.Pos{Line: 4, Column: 2},
+	})
+
+	var r Report
+	r.AddOnError(path.New("", "foo", "bar"), errDummy)
+	r.AddOnError(path.New("", "baz"), errDummy)
+	r.CorrelateProvenance(&p)
+
+	if m := r.Entries[0].Marker; m.Source != "base.yaml" || m.StartP == nil || m.StartP.Line != 4 {
+		t.Errorf("entry was not correlated: %+v", m)
+	}
+	if m := r.Entries[1].Marker; m.Source != "" || m.StartP != nil {
+		t.Errorf("entry without origin was correlated: %+v", m)
+	}
+	if s := r.Entries[0].String(); s != "base.yaml:4:2: error at $.foo.bar: dummy" {
+		t.Errorf("bad entry: %q", s)
+	}
+	if s := r.Entries[1].String(); s != "error at $.baz: dummy" {
+		t.Errorf("bad entry: %q", s)
+	}
+
+	// text edits can't be resolved against provenance
+	r = Report{}
+	r.AddOnError(path.New("", "foo"), WithFixes(errDummy, Fix{
+		Edits: []TextEdit{
+			{Path: path.New("", "foo"), Marker: tree.MarkerFromIndices(1, 2)},
+			{Marker: tree.MarkerFromIndices(3, 4)},
+		},
+	}))
+	r.CorrelateProvenance(&p)
+	if edits := r.Entries[0].Fixes[0].Edits; edits[0].Marker.StartP != nil || edits[1].Marker.StartP == nil {
+		t.Errorf("expected only the edit with a path to be cleared, got %+v", edits)
+	}
+}
+
+func TestEntryStringWithSource(t *testing.T) {
+	m := tree.Marker{
+		StartP: &tree.Pos{Line: 4, Column: 2},
+		Source: "file.yaml",
+	}
+	tests := []struct {
+		in  Entry
+		out string
+	}{
+		{
+			Entry{Kind: Error, Message: "bad", Context: path.New("", "foo"), Marker: m},
+			"file.yaml:4:2: error at $.foo: bad",
+		},
+		{
+			Entry{Kind: Warn, Message: "bad", Marker: m},
+			"file.yaml:4:2: warning: bad",
+		},
+		{
+			Entry{Kind: Error, Message: "bad", Context: path.New("", "foo"), Marker: tree.Marker{StartP: m.StartP}},
+			"error at $.foo, line 4 col 2: bad",
+		},
+	}
+	for i, test := range tests {
+		if s := test.in.String(); s != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, s)
+		}
+	}
+}
+
+func TestFixes(t *testing.T) {
+	fix := Fix{
+		Description: "remove foo",
+		Patch: []PatchOp{
+			{Op: OpRemove, Path: path.New("", "foo")},
+		},
+	}
+	var r Report
+	r.AddOnError(path.New("", "foo"), fmt.Errorf("wrapped: %w", WithFixes(errDummy, fix)))
+	r.AddOnError(path.New("", "bar"), errDummy)
+
+	if len(r.Entries[0].Fixes) != 1 || r.Entries[0].Fixes[0].Description != "remove foo" {
+		t.Errorf("entry did not record fix: %+v", r.Entries[0].Fixes)
+	}
+	if len(r.Entries[1].Fixes) != 0 {
+		t.Errorf("entry recorded unexpected fixes: %+v", r.Entries[1].Fixes)
+	}
+	if len(r.Fixes()) != 1 {
+		t.Errorf("expected 1 fix, got %d", len(r.Fixes()))
+	}
+	if !errors.Is(r.Entries[0], errDummy) {
+		t.Errorf("fixed entry does not unwrap to its original error")
+	}
+	if s := r.Entries[0].String(); s != "error at $.foo: wrapped: dummy" {
+		t.Errorf("bad entry: %q", s)
+	}
+}
+
+func TestRelated(t *testing.T) {
+	n := tree.MapNode{
+		Marker: tree.Marker{StartP: &tree.Pos{Line: 1, Column: 1}},
+		Children: map[string]tree.Node{
+			"a": tree.Leaf{Marker: tree.Marker{StartP: &tree.Pos{Line: 2, Column: 3}}},
+			"b": tree.Leaf{Marker: tree.Marker{StartP: &tree.Pos{Line: 5, Column: 3}}},
+		},
+	}
+	err := WithRelated(errDummy, Related{
+		Context: path.New("", "a"),
+		Note:    "conflicts with this",
+	})
+
+	var r Report
+	r.AddOnError(path.New("", "b"), err)
+	filtered := r.FilterKind(Error)
+	filtered.Correlate(n)
+
+	expected := "error at $.b, line 5 col 3: dummy\n\trelated at $.a, line 2 col 3: conflicts with this"
+	if s := filtered.Entries[0].String(); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if r.Entries[0].Related[0].Marker.StartP != nil {
+		t.Errorf("correlating a filtered report modified the original")
+	}
+	if !errors.Is(r.Entries[0], errDummy) {
+		t.Errorf("entry does not unwrap to its original error")
+	}
+
+	var p tree.Provenance
+	p.Record(path.New("", "a"), "base.yaml", tree.Marker{
+		StartP: &tree.Pos{Line: 7, Column: 1},
+	})
+	r.CorrelateProvenance(&p)
+	expected = "error at $.b: dummy\n\tbase.yaml:7:1: related at $.a: conflicts with this"
+	if s := r.Entries[0].String(); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+
+	// related locations survive a round trip through json
+	b, jerr := json.Marshal(r)
+	if jerr != nil {
+		t.Fatalf("marshaling: %v", jerr)
+	}
+	var out struct {
+		Entries []struct {
+			Related []struct {
+				Note string
+			}
+		}
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshaling: %v", err)
+	}
+	if len(out.Entries[0].Related) != 1 || out.Entries[0].Related[0].Note != "conflicts with this" {
+		t.Errorf("json lost related locations: %s", b)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	var r Report
+	r.AddOnError(path.New("", "foo"), errDummy)
+	if r.Incomplete {
+		t.Errorf("new report is incomplete")
+	}
+	r.Merge(Report{Incomplete: true})
+	if !r.Incomplete {
+		t.Errorf("merging an incomplete report did not make the report incomplete")
+	}
+	r.Merge(Report{})
+	if !r.Incomplete {
+		t.Errorf("merging a complete report made the report complete")
+	}
+	if !r.FilterKind(Warn).Incomplete || !r.Map(func(e Entry) Entry { return e }).Incomplete {
+		t.Errorf("derived report is not incomplete")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+// Sort sorts the entries by their position in the source, grouping entries
+// from several source documents by the Source of their marker. Entries
+// without a marker sort after those with one. Entries at the same position,
+// or without a position, are sorted by context path. The sort is stable.
+func (r *Report) Sort() {
+	sort.SliceStable(r.Entries, func(i, j int) bool {
+		return compareEntries(r.Entries[i], r.Entries[j]) < 0
+	})
+}
+
+func compareEntries(a, b Entry) int {
+	if a.Marker.StartP != nil && b.Marker.StartP != nil {
+		if c := strings.Compare(a.Marker.Source, b.Marker.Source); c != 0 {
+			return c
+		}
+	}
+	if c := comparePos(a.Marker.StartP, b.Marker.StartP); c != 0 {
+		return c
+	}
+	return path.Compare(a.Context, b.Context)
+}
+
+func comparePos(a, b *tree.Pos) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	for _, c := range [][2]int64{
+		{a.Line, b.Line},
+		{a.Column, b.Column},
+		{a.Index, b.Index},
+	} {
+		switch {
+		case c[0] < c[1]:
+			return -1
+		case c[0] > c[1]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// Deduplicate removes entries identical to an earlier entry, keeping the
+// first occurrence. Entries are identical if they have the same kind,
+// message, code, context and marker.
+func (r *Report) Deduplicate() {
+	seen := make(map[string]struct{}, len(r.Entries))
+	var entries []Entry
+	for _, e := range r.Entries {
+		key := entryKey(e)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		entries = append(entries, e)
+	}
+	r.Entries = entries
+}
+
+func entryKey(e Entry) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%#v\x00%s\x00%s\x00%s",
+		e.Kind.String(), e.Code, e.Message, e.Context.Path, e.Marker.Source, posKey(e.Marker.StartP), posKey(e.Marker.EndP))
+}
+
+func posKey(p *tree.Pos) string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d:%d", p.Index, p.Line, p.Column)
+}
+
+// Group is a set of entries sharing a key, as returned by GroupByPath and
+// GroupByKind.
+type Group struct {
+	// Key is the context path or kind the entries share, as a string.
+	Key    string
+	Report Report
+}
+
+// GroupByPath groups the entries by their context path. Groups are returned
+// in the order their first entry appears in r.
+func (r Report) GroupByPath() []Group {
+	return r.groupBy(func(e Entry) string {
+		return e.Context.String()
+	})
+}
+
+// GroupByKind groups the entries by their kind. Groups are returned in the
+// order their first entry appears in r; call Sort first for a stable order
+// within each group.
+func (r Report) GroupByKind() []Group {
+	return r.groupBy(func(e Entry) string {
+		return e.Kind.String()
+	})
+}
+
+func (r Report) groupBy(key func(Entry) string) []Group {
+	var groups []Group
+	index := map[string]int{}
+	for _, e := range r.Entries {
+		k := key(e)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, Group{Key: k})
+		}
+		groups[i].Report.Entries = append(groups[i].Report.Entries, e)
+	}
+	return groups
+}
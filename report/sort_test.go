@@ -0,0 +1,167 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package report
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+func entryAt(k Kind, msg string, line, col int64, p ...interface{}) Entry {
+	e := Entry{
+		Kind:    k,
+		Message: msg,
+		Context: path.New("", p...),
+	}
+	if line != 0 {
+		e.Marker = tree.Marker{
+			StartP: &tree.Pos{Line: line, Column: col},
+		}
+	}
+	return e
+}
+
+func entryIn(source string, msg string, line int64) Entry {
+	e := entryAt(Error, msg, line, 1, "x")
+	e.Marker.Source = source
+	return e
+}
+
+func messages(r Report) []string {
+	var ret []string
+	for _, e := range r.Entries {
+		ret = append(ret, e.Message)
+	}
+	return ret
+}
+
+func TestSort(t *testing.T) {
+	r := Report{
+		Entries: []Entry{
+			entryAt(Error, "no marker b", 0, 0, "b"),
+			entryAt(Error, "line 3", 3, 1, "z"),
+			entryAt(Warn, "no marker a 10", 0, 0, "a", 10),
+			entryAt(Error, "line 1 col 5", 1, 5, "y"),
+			entryAt(Error, "no marker a 2", 0, 0, "a", 2),
+			entryAt(Info, "line 1 col 2", 1, 2, "x"),
+			entryAt(Error, "line 3 path a", 3, 1, "a"),
+		},
+	}
+	r.Sort()
+	expected := []string{
+		"line 1 col 2",
+		"line 1 col 5",
+		"line 3 path a",
+		"line 3",
+		"no marker a 2",
+		"no marker a 10",
+		"no marker b",
+	}
+	if !reflect.DeepEqual(expected, messages(r)) {
+		t.Errorf("expected %v, got %v", expected, messages(r))
+	}
+}
+
+func TestSortSources(t *testing.T) {
+	r := Report{
+		Entries: []Entry{
+			entryIn("b.yaml", "b 1", 1),
+			entryAt(Error, "no marker", 0, 0, "x"),
+			entryIn("a.yaml", "a 2", 2),
+			entryIn("b.yaml", "b 3", 3),
+			entryIn("", "no source 4", 4),
+		},
+	}
+	r.Sort()
+	expected := []string{"no source 4", "a 2", "b 1", "b 3", "no marker"}
+	if !reflect.DeepEqual(expected, messages(r)) {
+		t.Errorf("expected %v, got %v", expected, messages(r))
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	r := Report{
+		Entries: []Entry{
+			entryAt(Error, "a", 1, 1, "foo"),
+			entryAt(Error, "b", 1, 1, "foo"),
+			entryAt(Error, "a", 1, 1, "foo"),
+			entryAt(Warn, "a", 1, 1, "foo"),
+			entryAt(Error, "a", 2, 1, "foo"),
+			entryAt(Error, "a", 1, 1, "foo", 0),
+			entryAt(Error, "a", 1, 1, "foo", "0"),
+			entryAt(Error, "a", 1, 1, "foo"),
+		},
+	}
+	orig := append([]Entry(nil), r.Entries...)
+	d := r
+	d.Deduplicate()
+	if len(d.Entries) != 6 {
+		t.Errorf("expected 6 entries, got %d: %v", len(d.Entries), d)
+	}
+	// the same position in different documents is different
+	sources := Report{
+		Entries: []Entry{
+			entryIn("a.yaml", "x", 1),
+			entryIn("b.yaml", "x", 1),
+			entryIn("a.yaml", "x", 1),
+		},
+	}
+	sources.Deduplicate()
+	if len(sources.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %d: %v", len(sources.Entries), sources)
+	}
+
+	// copies sharing the entries are left alone
+	if !reflect.DeepEqual(orig, r.Entries) {
+		t.Errorf("expected %v, got %v", orig, r.Entries)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	r := Report{
+		Entries: []Entry{
+			entryAt(Error, "1", 0, 0, "foo"),
+			entryAt(Warn, "2", 0, 0, "bar"),
+			entryAt(Error, "3", 0, 0, "bar"),
+			entryAt(Info, "4", 0, 0, "foo"),
+		},
+	}
+
+	byPath := r.GroupByPath()
+	if len(byPath) != 2 || byPath[0].Key != "$.foo" || byPath[1].Key != "$.bar" {
+		t.Fatalf("bad groups by path: %+v", byPath)
+	}
+	if !reflect.DeepEqual(messages(byPath[0].Report), []string{"1", "4"}) {
+		t.Errorf("bad group $.foo: %v", messages(byPath[0].Report))
+	}
+	if !reflect.DeepEqual(messages(byPath[1].Report), []string{"2", "3"}) {
+		t.Errorf("bad group $.bar: %v", messages(byPath[1].Report))
+	}
+
+	byKind := r.GroupByKind()
+	var keys []string
+	for _, g := range byKind {
+		keys = append(keys, g.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"error", "warning", "info"}) {
+		t.Errorf("bad groups by kind: %v", keys)
+	}
+	if !reflect.DeepEqual(messages(byKind[0].Report), []string{"1", "3"}) {
+		t.Errorf("bad group error: %v", messages(byKind[0].Report))
+	}
+}
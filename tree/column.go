@@ -0,0 +1,95 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"unicode/utf8"
+)
+
+// ColumnMode selects the unit columns are counted in.
+type ColumnMode int
+
+const (
+	// ByteColumns counts columns in bytes. It is the default.
+	ByteColumns ColumnMode = iota
+	// RuneColumns counts columns in Unicode code points, which is usually
+	// what people expect.
+	RuneColumns
+	// UTF16Columns counts columns in UTF-16 code units, as used by the
+	// Language Server Protocol and many editors.
+	UTF16Columns
+)
+
+// ColumnOptions controls how columns are computed.
+type ColumnOptions struct {
+	Mode ColumnMode
+	// TabWidth, if positive, makes tabs advance to the next tab stop, i.e.
+	// the next column after a multiple of TabWidth. This is useful for
+	// displaying positions, but not for editors, which count tabs as one
+	// character.
+	TabWidth int
+}
+
+// advance returns the column following a rune of size bytes starting at
+// column col.
+func (o ColumnOptions) advance(col int64, r rune, size int) int64 {
+	if r == '\t' && o.TabWidth > 0 {
+		tw := int64(o.TabWidth)
+		return ((col-1)/tw+1)*tw + 1
+	}
+	switch o.Mode {
+	case RuneColumns:
+		return col + 1
+	case UTF16Columns:
+		if r >= 0x10000 {
+			// encoded as a surrogate pair
+			return col + 2
+		}
+		return col + 1
+	default:
+		return col + int64(size)
+	}
+}
+
+// decode returns the first character of b and its size. In ByteColumns
+// mode every byte is a character.
+func (o ColumnOptions) decode(b []byte) (rune, int) {
+	if o.Mode == ByteColumns {
+		return rune(b[0]), 1
+	}
+	return utf8.DecodeRune(b)
+}
+
+// Column returns the one-based column of the byte at offset in line,
+// counted according to o. Offsets inside a multibyte character return the
+// character's column, and offsets past the end of line return the column
+// following the last character.
+func Column(line []byte, offset int, o ColumnOptions) int64 {
+	return o.columnFrom(line, 0, 1, offset)
+}
+
+// columnFrom is like Column, but starts counting at offset i which is known
+// to be at column col.
+func (o ColumnOptions) columnFrom(line []byte, i int, col int64, offset int) int64 {
+	for i < offset && i < len(line) {
+		r, size := o.decode(line[i:])
+		if i+size > offset {
+			break
+		}
+		col = o.advance(col, r, size)
+		i += size
+	}
+	return col
+}
@@ -0,0 +1,212 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"errors"
+
+	"github.com/coreos/vcontext/path"
+)
+
+// The functions in this file never modify the tree they are given. They
+// return a new tree which shares every node not on the edited path with
+// the original, so untouched nodes keep their markers.
+
+var (
+	ErrExists = errors.New("node already exists")
+)
+
+// Set returns a copy of n with the node at c replaced by v. If c ends in a
+// key missing from its map, the key is added. Setting the root returns v.
+func Set(n Node, c path.ContextPath, v Node) (Node, error) {
+	if c.Len() == 0 {
+		return v, nil
+	}
+	return edit(n, c, func(parent Node, e interface{}) (Node, error) {
+		switch p := parent.(type) {
+		case MapNode:
+			k, ok := e.(string)
+			if !ok {
+				return nil, ErrBadPath
+			}
+			p = copyMap(p)
+			if _, ok := p.Keys[k]; !ok {
+				p.Keys[k] = Leaf{}
+			}
+			p.Children[k] = v
+			return p, nil
+		case SliceNode:
+			i, ok := e.(int)
+			if !ok || i < 0 || i >= len(p.Children) {
+				return nil, ErrBadPath
+			}
+			p = copySlice(p, 0)
+			p.Children[i] = v
+			return p, nil
+		default:
+			return nil, ErrBadPath
+		}
+	})
+}
+
+// Insert returns a copy of n with v inserted at c. If c ends in an index,
+// v is inserted into the slice before that index, or appended if it is the
+// length of the slice. If c ends in a key, the key must not already exist
+// in its map; otherwise ErrExists is returned.
+func Insert(n Node, c path.ContextPath, v Node) (Node, error) {
+	if c.Len() == 0 {
+		return nil, ErrBadPath
+	}
+	return edit(n, c, func(parent Node, e interface{}) (Node, error) {
+		switch p := parent.(type) {
+		case MapNode:
+			k, ok := e.(string)
+			if !ok {
+				return nil, ErrBadPath
+			}
+			if _, ok := p.Children[k]; ok {
+				return nil, ErrExists
+			}
+			p = copyMap(p)
+			p.Keys[k] = Leaf{}
+			p.Children[k] = v
+			return p, nil
+		case SliceNode:
+			i, ok := e.(int)
+			if !ok || i < 0 || i > len(p.Children) {
+				return nil, ErrBadPath
+			}
+			p = copySlice(p, 1)
+			copy(p.Children[i+1:], p.Children[i:])
+			p.Children[i] = v
+			return p, nil
+		default:
+			return nil, ErrBadPath
+		}
+	})
+}
+
+// Delete returns a copy of n with the node at c removed. Later elements of
+// a slice move down to fill the gap.
+func Delete(n Node, c path.ContextPath) (Node, error) {
+	if c.Len() == 0 {
+		return nil, ErrBadPath
+	}
+	return edit(n, c, func(parent Node, e interface{}) (Node, error) {
+		switch p := parent.(type) {
+		case MapNode:
+			k, ok := e.(string)
+			if !ok {
+				return nil, ErrBadPath
+			}
+			if _, ok := p.Children[k]; !ok {
+				return nil, ErrBadPath
+			}
+			p = copyMap(p)
+			delete(p.Keys, k)
+			delete(p.Children, k)
+			return p, nil
+		case SliceNode:
+			i, ok := e.(int)
+			if !ok || i < 0 || i >= len(p.Children) {
+				return nil, ErrBadPath
+			}
+			children := make([]Node, 0, len(p.Children)-1)
+			children = append(children, p.Children[:i]...)
+			p.Children = append(children, p.Children[i+1:]...)
+			return p, nil
+		default:
+			return nil, ErrBadPath
+		}
+	})
+}
+
+// Move returns a copy of n with the node at from moved to to, which is set
+// as by Set. to is interpreted after from has been removed.
+func Move(n Node, from, to path.ContextPath) (Node, error) {
+	v, err := n.Get(from)
+	if err != nil {
+		return nil, err
+	}
+	if n, err = Delete(n, from); err != nil {
+		return nil, err
+	}
+	return Set(n, to, v)
+}
+
+// edit copies the nodes along c, calling f to produce a replacement for
+// the parent of the last element of c.
+func edit(n Node, c path.ContextPath, f func(parent Node, e interface{}) (Node, error)) (Node, error) {
+	if c.Len() == 1 {
+		return f(n, c.Head())
+	}
+	switch p := n.(type) {
+	case MapNode:
+		k, ok := c.Head().(string)
+		if !ok {
+			return nil, ErrBadPath
+		}
+		child, ok := p.Children[k]
+		if !ok {
+			return nil, ErrBadPath
+		}
+		child, err := edit(child, c.Tail(), f)
+		if err != nil {
+			return nil, err
+		}
+		p = copyMap(p)
+		p.Children[k] = child
+		return p, nil
+	case SliceNode:
+		i, ok := c.Head().(int)
+		if !ok || i < 0 || i >= len(p.Children) {
+			return nil, ErrBadPath
+		}
+		child, err := edit(p.Children[i], c.Tail(), f)
+		if err != nil {
+			return nil, err
+		}
+		p = copySlice(p, 0)
+		p.Children[i] = child
+		return p, nil
+	default:
+		return nil, ErrBadPath
+	}
+}
+
+func copyMap(m MapNode) MapNode {
+	children := make(map[string]Node, len(m.Children)+1)
+	for k, v := range m.Children {
+		children[k] = v
+	}
+	keys := make(map[string]Leaf, len(m.Keys)+1)
+	for k, v := range m.Keys {
+		keys[k] = v
+	}
+	m.Children = children
+	m.Keys = keys
+	return m
+}
+
+// copySlice copies s, growing its children by extra nil nodes.
+func copySlice(s SliceNode, extra int) SliceNode {
+	children := make([]Node, len(s.Children), len(s.Children)+extra)
+	copy(children, s.Children)
+	for i := 0; i < extra; i++ {
+		children = append(children, nil)
+	}
+	s.Children = children
+	return s
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+)
+
+// paths lists the paths of every node in n, in Walk order.
+func paths(n Node) []string {
+	var ret []string
+	_ = Walk(n, func(c path.ContextPath, n Node) error {
+		ret = append(ret, c.String())
+		return nil
+	}, nil)
+	return ret
+}
+
+func TestEdit(t *testing.T) {
+	newLeaf := Leaf{Marker: lineMarker(42)}
+	tests := []struct {
+		edit func(Node) (Node, error)
+		out  []string
+		err  error
+	}{
+		// set
+		{
+			edit: func(n Node) (Node, error) {
+				return Set(n, path.New("", "a"), SliceNode{})
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Set(n, path.New("", "b", 1, "d"), newLeaf)
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c", "$.b.1.d", "$.b.1.d"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Set(n, path.New("", "b", 2), newLeaf)
+			},
+			err: ErrBadPath,
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Set(n, path.New("", "x", "y"), newLeaf)
+			},
+			err: ErrBadPath,
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Set(n, path.New(""), newLeaf)
+			},
+			out: []string{"$"},
+		},
+		// insert
+		{
+			edit: func(n Node) (Node, error) {
+				return Insert(n, path.New("", "b", 0), newLeaf)
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.2", "$.b.2.c", "$.b.2.c"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Insert(n, path.New("", "b", 2), newLeaf)
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c", "$.b.2"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Insert(n, path.New("", "a"), newLeaf)
+			},
+			err: ErrExists,
+		},
+		// delete
+		{
+			edit: func(n Node) (Node, error) {
+				return Delete(n, path.New("", "b", 0))
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.0.c", "$.b.0.c"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Delete(n, path.New("", "a"))
+			},
+			out: []string{"$", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c"},
+		},
+		{
+			edit: func(n Node) (Node, error) {
+				return Delete(n, path.New("", "b", 1, "x"))
+			},
+			err: ErrBadPath,
+		},
+		// move
+		{
+			edit: func(n Node) (Node, error) {
+				return Move(n, path.New("", "b", 1), path.New("", "z"))
+			},
+			out: []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.z", "$.z", "$.z.c", "$.z.c"},
+		},
+	}
+
+	for i, test := range tests {
+		orig := walkTestTree()
+		out, err := test.edit(orig)
+		if err != test.err {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(paths(out), test.out) {
+			t.Errorf("#%d: expected %v, got %v", i, test.out, paths(out))
+		}
+		if !reflect.DeepEqual(orig, walkTestTree()) {
+			t.Errorf("#%d: original tree was modified", i)
+		}
+	}
+}
+
+func TestEditKeepsMarkers(t *testing.T) {
+	orig := MapNode{
+		Marker: lineMarker(1),
+		Keys: map[string]Leaf{
+			"a": {Marker: lineMarker(2)},
+			"b": {Marker: lineMarker(3)},
+		},
+		Children: map[string]Node{
+			"a": Leaf{Marker: lineMarker(2)},
+			"b": Leaf{Marker: lineMarker(3)},
+		},
+	}
+	out, err := Set(orig, path.New("", "a"), Leaf{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		c    path.ContextPath
+		line int64
+	}{
+		{path.New(""), 1},
+		{path.New("", Key("a")), 2},
+		{path.New("", "a"), 0},
+		{path.New("", "b"), 3},
+	} {
+		n, err := out.Get(test.c)
+		if err != nil {
+			t.Fatalf("%s: %v", test.c, err)
+		}
+		if line, _ := n.Start(); line != test.line {
+			t.Errorf("%s: expected line %d, got %d", test.c, test.line, line)
+		}
+	}
+}
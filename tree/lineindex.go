@@ -0,0 +1,161 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"errors"
+	"sort"
+	"unicode/utf8"
+)
+
+var (
+	ErrBadPosition = errors.New("invalid position")
+)
+
+// LineIndex maps between byte offsets in a source and lines and columns.
+// It is built once per source in O(n) and answers lookups in O(log n) plus,
+// for lines containing multibyte characters or tabs when those affect
+// columns, the length of the line. "\n", "\r\n" and "\r" all end lines,
+// as they do in yaml.
+type LineIndex struct {
+	source []byte
+	opts   ColumnOptions
+	// starts holds the offset of the first byte of each line
+	starts []int64
+	// ends holds the offset of the line break ending each line
+	ends []int64
+	// simple records for each line whether columns are byte offsets + 1
+	simple []bool
+}
+
+// NewLineIndex indexes source, counting columns according to opts.
+func NewLineIndex(source []byte, opts ColumnOptions) *LineIndex {
+	li := &LineIndex{
+		source: source,
+		opts:   opts,
+		starts: []int64{0},
+	}
+	simple := true
+	for i, c := range source {
+		switch {
+		case c == '\r' && i+1 < len(source) && source[i+1] == '\n':
+			// the \n ends the line
+		case c == '\n' || c == '\r':
+			end := int64(i)
+			if c == '\n' && i > 0 && source[i-1] == '\r' {
+				end--
+			}
+			li.ends = append(li.ends, end)
+			li.simple = append(li.simple, simple)
+			li.starts = append(li.starts, int64(i+1))
+			simple = true
+		case c >= utf8.RuneSelf:
+			simple = simple && opts.Mode == ByteColumns
+		case c == '\t':
+			simple = simple && opts.TabWidth <= 0
+		}
+	}
+	li.ends = append(li.ends, int64(len(source)))
+	li.simple = append(li.simple, simple)
+	return li
+}
+
+// Lines returns the number of lines in the source. A source always has at
+// least one line, and a trailing line break starts an empty line.
+func (li *LineIndex) Lines() int64 {
+	return int64(len(li.starts))
+}
+
+// Line returns the contents of the given one-based line, without its line
+// break, or nil if there is no such line.
+func (li *LineIndex) Line(line int64) []byte {
+	if line < 1 || line > li.Lines() {
+		return nil
+	}
+	return li.source[li.starts[line-1]:li.ends[line-1]]
+}
+
+// Position returns the position of the byte at offset. offset may be the
+// length of the source, to refer to its end. Every byte of a line break is
+// at the column following the last character of its line.
+func (li *LineIndex) Position(offset int64) (Pos, error) {
+	return li.positionFrom(offset, nil)
+}
+
+// positionFrom is like Position, but if hint is a position earlier on the
+// same line, counts columns from there instead of the start of the line.
+// This keeps filling in many positions on one long line linear.
+func (li *LineIndex) positionFrom(offset int64, hint *Pos) (Pos, error) {
+	if offset < 0 || offset > int64(len(li.source)) {
+		return Pos{}, ErrBadPosition
+	}
+	// the last line starting at or before offset
+	i := int64(sort.Search(len(li.starts), func(i int) bool {
+		return li.starts[i] > offset
+	})) - 1
+	return Pos{
+		Index:  offset,
+		Line:   i + 1,
+		Column: li.column(i, offset, hint),
+	}, nil
+}
+
+func (li *LineIndex) column(i, offset int64, hint *Pos) int64 {
+	start, end := li.starts[i], li.ends[i]
+	if offset > end {
+		offset = end
+	}
+	if li.simple[i] {
+		return offset - start + 1
+	}
+	from, col := 0, int64(1)
+	// hints inside a multibyte character don't know where it started
+	if hint != nil && hint.Line == i+1 && hint.Index <= offset &&
+		(hint.Index == int64(len(li.source)) || utf8.RuneStart(li.source[hint.Index])) {
+		from, col = int(hint.Index-start), hint.Column
+	}
+	return li.opts.columnFrom(li.source[start:end], from, col, int(offset-start))
+}
+
+// Offset returns the offset of the byte at the given one-based line and
+// column. The column following the last character of a line is valid and
+// refers to its line break.
+func (li *LineIndex) Offset(line, col int64) (int64, error) {
+	if line < 1 || line > li.Lines() || col < 1 {
+		return 0, ErrBadPosition
+	}
+	i := line - 1
+	start, end := li.starts[i], li.ends[i]
+	if li.simple[i] {
+		if start+col-1 > end {
+			return 0, ErrBadPosition
+		}
+		return start + col - 1, nil
+	}
+	c := int64(1)
+	for o := start; o <= end; {
+		if c == col {
+			return o, nil
+		} else if c > col || o == end {
+			// col is inside a tab or a multi-unit character, or past
+			// the end of the line
+			break
+		}
+		r, size := li.opts.decode(li.source[o:end])
+		c = li.opts.advance(c, r, size)
+		o += int64(size)
+	}
+	return 0, ErrBadPosition
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"testing"
+)
+
+func TestLineIndex(t *testing.T) {
+	tests := []struct {
+		src  string
+		opts ColumnOptions
+		// index, line, col; the position must round trip
+		out [][]int64
+		// positions with no offset
+		bad [][]int64
+	}{
+		{
+			src: "01\n3\n\n67",
+			out: [][]int64{
+				{0, 1, 1},
+				{1, 1, 2},
+				{2, 1, 3},
+				{3, 2, 1},
+				{5, 3, 1},
+				{6, 4, 1},
+				{7, 4, 2},
+				{8, 4, 3},
+			},
+			bad: [][]int64{
+				{0, 1},
+				{1, 4},
+				{5, 1},
+				{4, 4},
+			},
+		},
+		{
+			src:  "ä😀\tx\n",
+			opts: ColumnOptions{Mode: UTF16Columns, TabWidth: 4},
+			out: [][]int64{
+				{0, 1, 1},
+				{2, 1, 2},
+				{6, 1, 4},
+				{7, 1, 5},
+				{8, 1, 6},
+				{9, 2, 1},
+			},
+			bad: [][]int64{
+				// inside the surrogate pair
+				{1, 3},
+				{1, 7},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		li := NewLineIndex([]byte(test.src), test.opts)
+		for _, o := range test.out {
+			p, err := li.Position(o[0])
+			if err != nil {
+				t.Errorf("#%d: %d: %v", i, o[0], err)
+			} else if p.Line != o[1] || p.Column != o[2] {
+				t.Errorf("#%d: %d: expected %d:%d, got %d:%d", i, o[0], o[1], o[2], p.Line, p.Column)
+			}
+			offset, err := li.Offset(o[1], o[2])
+			if err != nil {
+				t.Errorf("#%d: %d:%d: %v", i, o[1], o[2], err)
+			} else if offset != o[0] {
+				t.Errorf("#%d: %d:%d: expected %d, got %d", i, o[1], o[2], o[0], offset)
+			}
+		}
+		for _, b := range test.bad {
+			if offset, err := li.Offset(b[0], b[1]); err != ErrBadPosition {
+				t.Errorf("#%d: %d:%d: expected ErrBadPosition, got %d %v", i, b[0], b[1], offset, err)
+			}
+		}
+		if _, err := li.Position(int64(len(test.src) + 1)); err != ErrBadPosition {
+			t.Errorf("#%d: expected ErrBadPosition past the end, got %v", i, err)
+		}
+	}
+}
+
+func TestLineIndexLineEndings(t *testing.T) {
+	// \n, \r\n, \r, \r\r\n (an empty line), then \n\r (also an empty line)
+	src := "a\nbc\r\nd\re\r\r\nf\n\rg"
+	expected := []string{"a", "bc", "d", "e", "", "f", "", "g"}
+	li := NewLineIndex([]byte(src), ColumnOptions{})
+	if li.Lines() != int64(len(expected)) {
+		t.Fatalf("expected %d lines, got %d", len(expected), li.Lines())
+	}
+	for i, e := range expected {
+		if l := string(li.Line(int64(i + 1))); l != e {
+			t.Errorf("line %d: expected %q, got %q", i+1, e, l)
+		}
+	}
+
+	tests := [][]int64{
+		// index, line, col
+		{0, 1, 1},
+		{1, 1, 2},
+		{2, 2, 1},
+		{3, 2, 2},
+		// both bytes of \r\n are at the end of the line
+		{4, 2, 3},
+		{5, 2, 3},
+		{6, 3, 1},
+		{7, 3, 2},
+		{8, 4, 1},
+		{10, 5, 1},
+		{11, 5, 1},
+		{12, 6, 1},
+		{14, 7, 1},
+		{15, 8, 1},
+	}
+	for _, test := range tests {
+		p, err := li.Position(test[0])
+		if err != nil {
+			t.Errorf("%d: %v", test[0], err)
+		} else if p.Line != test[1] || p.Column != test[2] {
+			t.Errorf("%d: expected %d:%d, got %d:%d", test[0], test[1], test[2], p.Line, p.Column)
+		}
+	}
+	if offset, err := li.Offset(2, 3); err != nil || offset != 4 {
+		t.Errorf("expected the end of line 2 at 4, got %d %v", offset, err)
+	}
+	if _, err := li.Offset(2, 4); err != ErrBadPosition {
+		t.Errorf("expected ErrBadPosition past the end of line 2, got %v", err)
+	}
+}
+
+func TestLineIndexLines(t *testing.T) {
+	li := NewLineIndex([]byte("a\n\nbc\n"), ColumnOptions{})
+	expected := []string{"a", "", "bc", ""}
+	if li.Lines() != int64(len(expected)) {
+		t.Fatalf("expected %d lines, got %d", len(expected), li.Lines())
+	}
+	for i, e := range expected {
+		if l := string(li.Line(int64(i + 1))); l != e {
+			t.Errorf("line %d: expected %q, got %q", i+1, e, l)
+		}
+	}
+	if li.Line(0) != nil || li.Line(5) != nil {
+		t.Errorf("expected nil for lines out of range")
+	}
+}
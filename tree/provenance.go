@@ -0,0 +1,89 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"github.com/coreos/vcontext/path"
+)
+
+// Provenance records which source document each path in a merged document
+// came from, as a marker whose Source names the document. It is a tree
+// keyed by path elements, so a path inherits the origin of its closest
+// recorded ancestor. The zero value is empty and ready to use.
+type Provenance struct {
+	origin   *Marker
+	children map[interface{}]*Provenance
+}
+
+func (p *Provenance) child(e interface{}, create bool) *Provenance {
+	if c, ok := p.children[e]; ok || !create {
+		return c
+	}
+	if p.children == nil {
+		p.children = map[interface{}]*Provenance{}
+	}
+	c := &Provenance{}
+	p.children[e] = c
+	return c
+}
+
+// Record records that the value at c came from source at marker m,
+// replacing any existing origin for c. If source is empty, m.Source is
+// kept.
+func (p *Provenance) Record(c path.ContextPath, source string, m Marker) {
+	for _, e := range c.Path {
+		p = p.child(e, true)
+	}
+	p.set(source, m)
+}
+
+func (p *Provenance) set(source string, m Marker) {
+	if source != "" {
+		m.Source = source
+	}
+	p.origin = &m
+}
+
+// RecordTree records every node of n as coming from source. Recording the
+// trees of a base document and then of each overlay, in the order they were
+// merged, makes each path resolve to the last document that set it.
+func (p *Provenance) RecordTree(source string, n Node) {
+	// Walk only fails if the WalkFunc does
+	_ = Walk(n, func(c path.ContextPath, n Node) error {
+		p.Record(c, source, n.GetMarker())
+		return nil
+	}, nil)
+}
+
+// Lookup returns the origin of c, which is that of c's deepest ancestor
+// (or c itself) with a recorded origin. It returns false if there is none.
+func (p *Provenance) Lookup(c path.ContextPath) (Marker, bool) {
+	var found *Marker
+	for _, e := range c.Path {
+		if p.origin != nil {
+			found = p.origin
+		}
+		if p = p.child(e, false); p == nil {
+			break
+		}
+	}
+	if p != nil && p.origin != nil {
+		found = p.origin
+	}
+	if found == nil {
+		return Marker{}, false
+	}
+	return *found, true
+}
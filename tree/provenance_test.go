@@ -0,0 +1,110 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+)
+
+func lineMarker(line int64) Marker {
+	return Marker{
+		StartP: &Pos{Line: line, Column: 1},
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	// base: {"a": {"b": 1, "c": [1, 2]}}
+	base := MapNode{
+		Marker: lineMarker(1),
+		Keys: map[string]Leaf{
+			"a": {Marker: lineMarker(2)},
+		},
+		Children: map[string]Node{
+			"a": MapNode{
+				Marker: lineMarker(2),
+				Keys: map[string]Leaf{
+					"b": {Marker: lineMarker(3)},
+					"c": {Marker: lineMarker(4)},
+				},
+				Children: map[string]Node{
+					"b": Leaf{Marker: lineMarker(3)},
+					"c": SliceNode{
+						Marker: lineMarker(4),
+						Children: []Node{
+							Leaf{Marker: lineMarker(5)},
+							Leaf{Marker: lineMarker(6)},
+						},
+					},
+				},
+			},
+		},
+	}
+	// overlay: {"a": {"b": 2}}
+	overlay := MapNode{
+		Marker: lineMarker(1),
+		Keys: map[string]Leaf{
+			"a": {Marker: lineMarker(1)},
+		},
+		Children: map[string]Node{
+			"a": MapNode{
+				Marker: lineMarker(1),
+				Keys: map[string]Leaf{
+					"b": {Marker: lineMarker(2)},
+				},
+				Children: map[string]Node{
+					"b": Leaf{Marker: lineMarker(2)},
+				},
+			},
+		},
+	}
+
+	var p Provenance
+	if _, ok := p.Lookup(path.New("", "a")); ok {
+		t.Errorf("empty provenance returned an origin")
+	}
+	p.RecordTree("base.yaml", base)
+	p.RecordTree("overlay.yaml", overlay)
+	p.Record(path.New("", "d"), "generated", Marker{})
+
+	tests := []struct {
+		in     path.ContextPath
+		source string
+		line   int64
+		ok     bool
+	}{
+		{path.New(""), "overlay.yaml", 1, true},
+		{path.New("", "a", "b"), "overlay.yaml", 2, true},
+		{path.New("", "a", Key("b")), "overlay.yaml", 2, true},
+		{path.New("", "a", "c"), "base.yaml", 4, true},
+		{path.New("", "a", "c", 1), "base.yaml", 6, true},
+		{path.New("", "a", Key("c")), "base.yaml", 4, true},
+		// falls back to the deepest recorded ancestor
+		{path.New("", "a", "c", 7, "x"), "base.yaml", 4, true},
+		{path.New("", "d", "e"), "generated", 0, true},
+	}
+
+	for i, test := range tests {
+		m, ok := p.Lookup(test.in)
+		if ok != test.ok || m.Source != test.source {
+			t.Errorf("#%d: expected %q %v, got %q %v", i, test.source, test.ok, m.Source, ok)
+			continue
+		}
+		if line, _ := m.Start(); line != test.line {
+			t.Errorf("#%d: expected line %d, got %d", i, test.line, line)
+		}
+	}
+}
@@ -36,32 +36,40 @@ type Node interface {
 }
 
 // FixLineColumn populates the Line and Column of nodes that only have Index set.
+// Columns are counted in bytes.
 func FixLineColumn(n Node, source []byte) {
-	fixLineColumn(n.pos(), source)
+	fixLineColumn(n.pos(), source, ColumnOptions{})
 }
 
-func fixLineColumn(p []*Pos, source []byte) {
+// FixLineColumnWithOptions is like FixLineColumn, but counts columns as
+// specified by opts.
+func FixLineColumnWithOptions(n Node, source []byte, opts ColumnOptions) {
+	fixLineColumn(n.pos(), source, opts)
+}
+
+// FixIndex populates the Index of nodes that only have Line and Column set,
+// with columns counted as specified by opts. Positions that do not exist in
+// source are left unchanged.
+func FixIndex(n Node, source []byte, opts ColumnOptions) {
+	li := NewLineIndex(source, opts)
+	for _, pos := range n.pos() {
+		if offset, err := li.Offset(pos.Line, pos.Column); err == nil {
+			pos.Index = offset
+		}
+	}
+}
+
+func fixLineColumn(p []*Pos, source []byte, opts ColumnOptions) {
 	sort.Slice(p, func(i, j int) bool {
 		return p[i].Index < p[j].Index
 	})
-	pi := 0
-	line, col := int64(1), int64(1)
-	for i, c := range source {
-		if pi == len(p) {
-			return
-		}
-		for int64(i) == p[pi].Index {
-			p[pi].Line = line
-			p[pi].Column = col
-			pi++
-			if pi == len(p) {
-				return
-			}
-		}
-		col++
-		if c == '\n' {
-			line++
-			col = 1
+	li := NewLineIndex(source, opts)
+	var prev *Pos
+	for _, pos := range p {
+		if lc, err := li.positionFrom(pos.Index, prev); err == nil {
+			pos.Line = lc.Line
+			pos.Column = lc.Column
+			prev = &lc
 		}
 	}
 }
@@ -96,6 +104,11 @@ func posLC(p *Pos) (int64, int64) {
 type Marker struct {
 	StartP *Pos
 	EndP   *Pos
+
+	// Source optionally names the document the marker refers to, e.g. a
+	// file name. Provenance sets it, since markers of a merged document
+	// refer to several documents.
+	Source string `json:",omitempty"`
 }
 
 func (m Marker) Start() (int64, int64) {
@@ -106,8 +119,13 @@ func (m Marker) End() (int64, int64) {
 	return posLC(m.EndP)
 }
 
+// String returns the start of the marker as "line 4 col 2", or, if the
+// marker has a Source, as "file.yaml:4:2".
 func (m Marker) String() string {
 	// Just do start for now, figure out end later
+	if m.Source != "" && m.StartP != nil {
+		return fmt.Sprintf("%s:%d:%d", m.Source, m.StartP.Line, m.StartP.Column)
+	}
 	return posString(m.StartP)
 }
 
@@ -126,6 +144,51 @@ func MarkerFromIndices(start, end int64) Marker {
 	return m
 }
 
+// Options configures how the json and yaml packages build trees.
+type Options struct {
+	// Source is recorded on every marker in the tree.
+	Source string
+	// Columns controls how columns are counted. If nil, each front end
+	// uses its default: bytes for json, and runes for yaml.
+	Columns *ColumnOptions
+}
+
+// Option sets a field of Options.
+type Option func(*Options)
+
+// WithSource sets the name of the document a tree is built from, e.g. its
+// file name. It is recorded on every marker in the tree.
+func WithSource(source string) Option {
+	return func(o *Options) {
+		o.Source = source
+	}
+}
+
+// WithColumns sets how columns are counted, e.g. in UTF-16 code units for
+// use with the Language Server Protocol.
+func WithColumns(opts ColumnOptions) Option {
+	return func(o *Options) {
+		o.Columns = &opts
+	}
+}
+
+// NewOptions returns the Options resulting from applying opts in order.
+func NewOptions(opts ...Option) Options {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ColumnsOr returns o.Columns, or def if it is unset.
+func (o Options) ColumnsOr(def ColumnOptions) ColumnOptions {
+	if o.Columns == nil {
+		return def
+	}
+	return *o.Columns
+}
+
 func appendPos(l []*Pos, p *Pos) []*Pos {
 	if p != nil {
 		return append(l, p)
@@ -133,10 +196,70 @@ func appendPos(l []*Pos, p *Pos) []*Pos {
 	return l
 }
 
+// Style records how a node was written, for formats with several ways of
+// writing the same value. It is used when serializing a tree.
+type Style int
+
+const (
+	DefaultStyle Style = iota
+	// FlowStyle is a map or slice written inline, e.g. [1, 2] in yaml.
+	FlowStyle
+	SingleQuotedStyle
+	DoubleQuotedStyle
+	// LiteralStyle is a yaml block scalar written with |.
+	LiteralStyle
+	// FoldedStyle is a yaml block scalar written with >.
+	FoldedStyle
+)
+
+// Comments holds the comments attached to a node, without their comment
+// markers' positions. Head comments precede the node, line comments follow
+// it on the same line and foot comments follow it on later lines.
+type Comments struct {
+	Head string
+	Line string
+	Foot string
+}
+
+// Format holds details of how a node was written which don't affect its
+// value, so they can be preserved when the tree is serialized again.
+type Format struct {
+	Style    Style
+	Comments Comments
+	// Text is the source text of a scalar, without quotes in yaml, and Tag
+	// its yaml tag. They let scalars be written back as they were, e.g.
+	// 0o17 rather than 15, as long as they still encode the leaf's value.
+	Text string
+	Tag  string
+}
+
 type MapNode struct {
 	Marker
 	Children map[string]Node
 	Keys     map[string]Leaf
+	Format   Format
+}
+
+// OrderedKeys returns m's keys in the order they appear in the source.
+// Keys without a marker, e.g. ones added with Set, follow in lexical order.
+func (m MapNode) OrderedKeys() []string {
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := m.Keys[keys[i]].StartP, m.Keys[keys[j]].StartP
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		default:
+			return a.Index < b.Index
+		}
+	})
+	return keys
 }
 
 func (m MapNode) Get(cxt path.ContextPath) (Node, error) {
@@ -175,6 +298,10 @@ func (m MapNode) pos() []*Pos {
 
 type Leaf struct {
 	Marker
+	// Value is the leaf's value as decoded by encoding/json or yaml, or nil
+	// for null. Leaves which are keys of a MapNode have no value.
+	Value  interface{}
+	Format Format
 }
 
 func (l Leaf) pos() []*Pos {
@@ -191,6 +318,7 @@ func (k Leaf) Get(ctx path.ContextPath) (Node, error) {
 type SliceNode struct {
 	Marker
 	Children []Node
+	Format   Format
 }
 
 func (s SliceNode) Get(ctx path.ContextPath) (Node, error) {
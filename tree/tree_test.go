@@ -21,9 +21,10 @@ import (
 
 func TestFixLineColumn(t *testing.T) {
 	tests := []struct {
-		in  []int64
-		src string
-		out [][]int64 //list of index, line, col
+		in   []int64
+		src  string
+		opts ColumnOptions
+		out  [][]int64 //list of index, line, col
 	}{
 		{
 			in:  []int64{0, 1, 1, 2, 3, 4, 5, 6, 7, 8, 9},
@@ -42,6 +43,60 @@ func TestFixLineColumn(t *testing.T) {
 				{9, 4, 1},
 			},
 		},
+		// 0 is a 2 byte, 1 rune, 1 UTF-16 unit character; 2 is a 4 byte,
+		// 1 rune, 2 UTF-16 unit character. In byte mode every byte has its
+		// own column; otherwise positions inside a character get its column.
+		{
+			in:  []int64{0, 1, 2, 6, 7, 8},
+			src: "ä😀x\ny",
+			out: [][]int64{
+				{0, 1, 1},
+				{1, 1, 2},
+				{2, 1, 3},
+				{6, 1, 7},
+				{7, 1, 8},
+				{8, 2, 1},
+			},
+		},
+		{
+			in:   []int64{0, 1, 2, 6, 7, 8},
+			src:  "ä😀x\ny",
+			opts: ColumnOptions{Mode: RuneColumns},
+			out: [][]int64{
+				{0, 1, 1},
+				{1, 1, 1},
+				{2, 1, 2},
+				{6, 1, 3},
+				{7, 1, 4},
+				{8, 2, 1},
+			},
+		},
+		{
+			in:   []int64{0, 2, 6, 7, 8},
+			src:  "ä😀x\ny",
+			opts: ColumnOptions{Mode: UTF16Columns},
+			out: [][]int64{
+				{0, 1, 1},
+				{2, 1, 2},
+				{6, 1, 4},
+				{7, 1, 5},
+				{8, 2, 1},
+			},
+		},
+		// tabs
+		{
+			in:   []int64{0, 1, 2, 3, 4, 5},
+			src:  "\tab\tc\t",
+			opts: ColumnOptions{Mode: RuneColumns, TabWidth: 4},
+			out: [][]int64{
+				{0, 1, 1},
+				{1, 1, 5},
+				{2, 1, 6},
+				{3, 1, 7},
+				{4, 1, 9},
+				{5, 1, 10},
+			},
+		},
 	}
 
 	for i, test := range tests {
@@ -55,7 +110,7 @@ func TestFixLineColumn(t *testing.T) {
 				Column: test.out[j][2],
 			}
 		}
-		fixLineColumn(p, []byte(test.src))
+		fixLineColumn(p, []byte(test.src), test.opts)
 		for _, pos := range p {
 			exp := expected[pos.Index]
 			if !reflect.DeepEqual(*pos, exp) {
@@ -64,3 +119,27 @@ func TestFixLineColumn(t *testing.T) {
 		}
 	}
 }
+
+func TestColumn(t *testing.T) {
+	line := []byte("\tä😀x")
+	tests := []struct {
+		offset int
+		opts   ColumnOptions
+		out    int64
+	}{
+		{0, ColumnOptions{}, 1},
+		{2, ColumnOptions{}, 3},
+		{2, ColumnOptions{Mode: RuneColumns}, 2},
+		{3, ColumnOptions{}, 4},
+		{7, ColumnOptions{}, 8},
+		{7, ColumnOptions{Mode: RuneColumns}, 4},
+		{7, ColumnOptions{Mode: UTF16Columns}, 5},
+		{7, ColumnOptions{Mode: UTF16Columns, TabWidth: 8}, 12},
+		{100, ColumnOptions{Mode: RuneColumns}, 5},
+	}
+	for i, test := range tests {
+		if out := Column(line, test.offset, test.opts); out != test.out {
+			t.Errorf("#%d: expected %d, got %d", i, test.out, out)
+		}
+	}
+}
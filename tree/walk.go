@@ -0,0 +1,111 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/coreos/vcontext/path"
+)
+
+var (
+	// SkipChildren can be returned by a pre-order WalkFunc to skip the
+	// children of the node. The post-order WalkFunc is still called for
+	// the node itself.
+	SkipChildren = errors.New("skip children")
+	// SkipAll can be returned by a WalkFunc to stop the walk. Walk then
+	// returns nil.
+	SkipAll = errors.New("skip all")
+)
+
+// WalkFunc is called by Walk for each node, with the node's path from the
+// root. The path's underlying array is reused, so c must be copied if it is
+// retained (see path.ContextPath.Append). If a WalkFunc returns an error
+// other than SkipChildren or SkipAll, Walk stops and returns it.
+type WalkFunc func(c path.ContextPath, n Node) error
+
+// Walk traverses the tree rooted at n depth first. It calls pre for each
+// node before its children and post after them; either may be nil. The
+// children of a MapNode are visited in order of their keys, and each key's
+// Leaf is visited (at a path ending in a Key) just before its value. Nil
+// nodes are not visited.
+func Walk(n Node, pre, post WalkFunc) error {
+	err := walk(path.ContextPath{}, n, pre, post)
+	if err == SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walk(c path.ContextPath, n Node, pre, post WalkFunc) error {
+	if n == nil {
+		return nil
+	}
+	skip := false
+	if pre != nil {
+		switch err := pre(c, n); err {
+		case nil:
+		case SkipChildren:
+			skip = true
+		default:
+			return err
+		}
+	}
+	if !skip {
+		switch v := n.(type) {
+		case MapNode:
+			for _, k := range sortedKeys(v) {
+				if key, ok := v.Keys[k]; ok {
+					if err := walk(c.Append(Key(k)), key, pre, post); err != nil {
+						return err
+					}
+				}
+				if child, ok := v.Children[k]; ok {
+					if err := walk(c.Append(k), child, pre, post); err != nil {
+						return err
+					}
+				}
+			}
+		case SliceNode:
+			for i, child := range v.Children {
+				if err := walk(c.Append(i), child, pre, post); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if post != nil {
+		if err := post(c, n); err != nil && err != SkipChildren {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the union of the keys of m's Children and Keys, sorted.
+func sortedKeys(m MapNode) []string {
+	keys := make([]string, 0, len(m.Children))
+	for k := range m.Children {
+		keys = append(keys, k)
+	}
+	for k := range m.Keys {
+		if _, ok := m.Children[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package tree
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+)
+
+// {"b": [1, {"c": 2}], "a": 3}
+func walkTestTree() Node {
+	return MapNode{
+		Keys: map[string]Leaf{
+			"a": {},
+			"b": {},
+		},
+		Children: map[string]Node{
+			"b": SliceNode{
+				Children: []Node{
+					Leaf{},
+					MapNode{
+						Keys: map[string]Leaf{
+							"c": {},
+						},
+						Children: map[string]Node{
+							"c": Leaf{},
+						},
+					},
+				},
+			},
+			"a": Leaf{},
+		},
+	}
+}
+
+func recorder(visited *[]string, ret map[string]error) WalkFunc {
+	return func(c path.ContextPath, n Node) error {
+		*visited = append(*visited, c.String())
+		return ret[c.String()]
+	}
+}
+
+func TestWalk(t *testing.T) {
+	errTest := errors.New("test")
+	tests := []struct {
+		pre     map[string]error
+		post    map[string]error
+		preOut  []string
+		postOut []string
+		err     error
+	}{
+		{
+			preOut:  []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c"},
+			postOut: []string{"$.a", "$.a", "$.b", "$.b.0", "$.b.1.c", "$.b.1.c", "$.b.1", "$.b", "$"},
+		},
+		{
+			pre:     map[string]error{"$.b": SkipChildren},
+			preOut:  []string{"$", "$.a", "$.a", "$.b", "$.b"},
+			postOut: []string{"$.a", "$.a", "$.b", "$.b", "$"},
+		},
+		{
+			pre:     map[string]error{"$.b.0": SkipAll},
+			preOut:  []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0"},
+			postOut: []string{"$.a", "$.a", "$.b"},
+		},
+		{
+			post:    map[string]error{"$.b.1": errTest},
+			preOut:  []string{"$", "$.a", "$.a", "$.b", "$.b", "$.b.0", "$.b.1", "$.b.1.c", "$.b.1.c"},
+			postOut: []string{"$.a", "$.a", "$.b", "$.b.0", "$.b.1.c", "$.b.1.c", "$.b.1"},
+			err:     errTest,
+		},
+	}
+
+	for i, test := range tests {
+		var pre, post []string
+		err := Walk(walkTestTree(), recorder(&pre, test.pre), recorder(&post, test.post))
+		if err != test.err {
+			t.Errorf("#%d: expected error %v, got %v", i, test.err, err)
+		}
+		if !reflect.DeepEqual(pre, test.preOut) {
+			t.Errorf("#%d: expected pre-order %v, got %v", i, test.preOut, pre)
+		}
+		if !reflect.DeepEqual(post, test.postOut) {
+			t.Errorf("#%d: expected post-order %v, got %v", i, test.postOut, post)
+		}
+	}
+}
+
+func TestWalkKeys(t *testing.T) {
+	var keys []path.ContextPath
+	err := Walk(walkTestTree(), func(c path.ContextPath, n Node) error {
+		if c.Len() > 0 {
+			if _, ok := c.Path[c.Len()-1].(Key); ok {
+				keys = append(keys, c.Copy())
+			}
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []path.ContextPath{
+		path.New("", Key("a")),
+		path.New("", Key("b")),
+		path.New("", "b", 1, Key("c")),
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package validate
+
+import (
+	"reflect"
+	"sync"
+)
+
+// plans caches what the walker and DefaultValidator work out about each type
+// by reflection, which otherwise dominates the time spent validating small
+// values.
+var plans = new(planCache)
+
+type planCache struct {
+	methods sync.Map // reflect.Type -> methodPlan
+	fields  sync.Map // fieldsKey -> []fieldPlan
+}
+
+// methodPlan records which of the validation methods a type has, on values
+// or on pointers only. Pointer types never have any, since the walker
+// validates what they point to instead.
+type methodPlan struct {
+	validate, validatePtr               bool
+	validateContext, validateContextPtr bool
+}
+
+func (c *planCache) methodsOf(t reflect.Type) methodPlan {
+	if p, ok := c.methods.Load(t); ok {
+		return p.(methodPlan)
+	}
+	var p methodPlan
+	if t.Kind() != reflect.Ptr {
+		ptr := reflect.PtrTo(t)
+		p.validate = t.Implements(validatorType)
+		p.validatePtr = !p.validate && ptr.Implements(validatorType)
+		p.validateContext = t.Implements(contextValidatorType)
+		p.validateContextPtr = !p.validateContext && ptr.Implements(contextValidatorType)
+	}
+	c.methods.Store(t, p)
+	return p
+}
+
+type fieldsKey struct {
+	t   reflect.Type
+	tag string
+}
+
+// fieldPlan is a field of a struct, or of a struct embedded in it, as
+// flattened by GetFields.
+type fieldPlan struct {
+	index []int
+	name  string
+	// dynamic is set for embedded interfaces, whose fields depend on the
+	// value they hold.
+	dynamic bool
+}
+
+func (c *planCache) fieldsOf(t reflect.Type, tag string) []fieldPlan {
+	key := fieldsKey{t: t, tag: tag}
+	if p, ok := c.fields.Load(key); ok {
+		return p.([]fieldPlan)
+	}
+	p := planFields(t, tag, nil)
+	c.fields.Store(key, p)
+	return p
+}
+
+func planFields(t reflect.Type, tag string, index []int) []fieldPlan {
+	var ret []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		switch {
+		case !field.Anonymous:
+			ret = append(ret, fieldPlan{
+				index: fieldIndex,
+				name:  FieldName(StructField{StructField: field}, tag),
+			})
+		case field.Type.Kind() == reflect.Struct:
+			ret = append(ret, planFields(field.Type, tag, fieldIndex)...)
+		case field.Type.Kind() == reflect.Interface:
+			ret = append(ret, fieldPlan{
+				index:   fieldIndex,
+				dynamic: true,
+			})
+		}
+		// like GetFields, ignore other embedded types, e.g. pointers
+	}
+	return ret
+}
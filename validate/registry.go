@@ -0,0 +1,120 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package validate
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// Compose returns a CustomValidator calling each of fs in order and merging
+// their reports.
+func Compose(fs ...CustomValidator) CustomValidator {
+	return func(v reflect.Value, c path.ContextPath) (r report.Report) {
+		for _, f := range fs {
+			r.Merge(f(v, c))
+		}
+		return
+	}
+}
+
+type interfaceValidator struct {
+	iface reflect.Type
+	f     CustomValidator
+}
+
+// Registry holds validators for types which can't have a Validate method,
+// e.g. types from other packages like net.IP. The zero value is an empty
+// registry. Validators must not be registered while the registry is being
+// used to validate.
+type Registry struct {
+	types      map[reflect.Type][]CustomValidator
+	interfaces []interfaceValidator
+}
+
+// Register registers f to validate values of type t. If t is an interface
+// type, f validates values of every non-pointer type implementing it, or
+// whose pointer type does, like DefaultValidator does for Validate methods.
+// In the latter case f is passed the value's address, or a pointer to a
+// copy if it isn't addressable. Otherwise f validates values of exactly
+// type t. Several validators may be registered for a type; they
+// are called in the order they were registered, validators for concrete
+// types first.
+func (r *Registry) Register(t reflect.Type, f CustomValidator) {
+	if t.Kind() == reflect.Interface {
+		r.interfaces = append(r.interfaces, interfaceValidator{
+			iface: t,
+			f:     f,
+		})
+		return
+	}
+	if r.types == nil {
+		r.types = make(map[reflect.Type][]CustomValidator)
+	}
+	r.types[t] = append(r.types[t], f)
+}
+
+// Validate is a CustomValidator calling the validators registered for v's
+// type.
+func (r *Registry) Validate(v reflect.Value, c path.ContextPath) (ret report.Report) {
+	t := v.Type()
+	for _, f := range r.types[t] {
+		ret.Merge(f(v, c))
+	}
+	for _, iv := range r.interfaces {
+		if impl, ok := implementer(v, iv.iface); ok {
+			ret.Merge(iv.f(impl, c))
+		}
+	}
+	return
+}
+
+// implementer returns v if its type implements iface, or else its address
+// or a pointer to a copy of it if that does, like implementation. Pointers
+// never implement iface, since the walker visits what they point to next.
+func implementer(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Ptr:
+		return reflect.Value{}, false
+	case t.Implements(iface):
+		return v, true
+	case !v.CanInterface() || !reflect.PtrTo(t).Implements(iface):
+		// values of unexported fields can't be converted to a pointer
+		// the method could be called on
+		return reflect.Value{}, false
+	}
+	obj, _ := implementation(v, false, true)
+	return reflect.ValueOf(obj), true
+}
+
+// Validator returns a CustomValidator calling DefaultValidator and then the
+// validators registered in r.
+func (r *Registry) Validator() CustomValidator {
+	return Compose(DefaultValidator, r.Validate)
+}
+
+// ContextValidator returns a ContextValidator calling DefaultContextValidator
+// and then the validators registered in r.
+func (r *Registry) ContextValidator() ContextValidator {
+	return func(ctx context.Context, v reflect.Value, c path.ContextPath) (ret report.Report) {
+		ret.Merge(DefaultContextValidator(ctx, v, c))
+		ret.Merge(r.Validate(v, c))
+		return
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package validate
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+)
+
+// Rule validates a T found at c. It is a type-safe alternative to a
+// CustomValidator checking the type of the values it is passed.
+type Rule[T any] func(v T, c path.ContextPath) report.Report
+
+// All returns a Rule calling each of rules in order and merging their
+// reports.
+func All[T any](rules ...Rule[T]) Rule[T] {
+	return func(v T, c path.ContextPath) (r report.Report) {
+		for _, rule := range rules {
+			r.Merge(rule(v, c))
+		}
+		return
+	}
+}
+
+// Validator returns a CustomValidator calling f on values of type T, or,
+// if T is an interface, on values of non-pointer types implementing it or
+// their addresses, as Registry.Register does, and ignoring other values.
+// Values of unexported fields are ignored too, since they can't be
+// converted to a T.
+func (f Rule[T]) Validator() CustomValidator {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return func(v reflect.Value, c path.ContextPath) report.Report {
+		if !v.CanInterface() {
+			return report.Report{}
+		}
+		if t.Kind() == reflect.Interface {
+			impl, ok := implementer(v, t)
+			if !ok {
+				return report.Report{}
+			}
+			v = impl
+		} else if v.Type() != t {
+			return report.Report{}
+		}
+		return f(v.Interface().(T), c)
+	}
+}
+
+// Register registers rules in r for type T, as by r.Register.
+func Register[T any](r *Registry, rules ...Rule[T]) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for _, rule := range rules {
+		r.Register(t, rule.Validator())
+	}
+}
+
+// ValidateWith validates thing like ValidateWithOptions, and also calls
+// rules on every T reached, including thing itself.
+func ValidateWith[T any](thing T, opts Options, rules ...Rule[T]) report.Report {
+	return ValidateWithContext(context.Background(), thing, opts, rules...)
+}
+
+// ValidateWithContext is like ValidateWith, but stops when ctx is done, like
+// ValidateContext.
+func ValidateWithContext[T any](ctx context.Context, thing T, opts Options, rules ...Rule[T]) report.Report {
+	f := opts.validator()
+	rule := All(rules...).Validator()
+	opts.ContextValidator = func(ctx context.Context, v reflect.Value, c path.ContextPath) (r report.Report) {
+		r.Merge(f(ctx, v, c))
+		r.Merge(rule(v, c))
+		return
+	}
+	return ValidateWithOptionsContext(ctx, thing, opts)
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/validate"
+)
+
+var (
+	errBadIP = errors.New("bad ip")
+)
+
+type Host struct {
+	Addr    net.IP       `json:"addr"`
+	Aliases []net.IP     `json:"aliases"`
+	Named   fmt.Stringer `json:"named"`
+	Label   Label        `json:"label"`
+	Test    Test2        `json:"test"`
+}
+
+type Name string
+
+func (n Name) String() string {
+	return string(n)
+}
+
+// Label implements fmt.Stringer on pointers only.
+type Label string
+
+func (l *Label) String() string {
+	return string(*l)
+}
+
+func validateIP(v reflect.Value, c path.ContextPath) (r report.Report) {
+	if len(v.Bytes()) != 0 && v.Interface().(net.IP).To16() == nil {
+		r.AddOnError(c, errBadIP)
+	}
+	return
+}
+
+func validateNotEmpty(v reflect.Value, c path.ContextPath) (r report.Report) {
+	if v.Len() == 0 {
+		r.AddOnWarn(c, errDummy)
+	}
+	return
+}
+
+func validateStringer(v reflect.Value, c path.ContextPath) (r report.Report) {
+	if v.Interface().(fmt.Stringer).String() == "" {
+		r.AddOnInfo(c, errDummy)
+	}
+	return
+}
+
+func TestRegistry(t *testing.T) {
+	var reg validate.Registry
+	reg.Register(reflect.TypeOf(net.IP{}), validateIP)
+	reg.Register(reflect.TypeOf(net.IP{}), validateNotEmpty)
+	reg.Register(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), validateStringer)
+
+	in := Host{
+		Addr:    net.IP{1, 2, 3},
+		Aliases: []net.IP{net.ParseIP("10.0.0.1"), {}},
+		Named:   Name(""),
+	}
+	var expected report.Report
+	// validators registered for a type run in order
+	expected.AddOnError(path.New("json", "addr"), errBadIP)
+	expected.AddOnWarn(path.New("json", "aliases", 1), errDummy)
+	// interfaces match the concrete values implementing them
+	expected.AddOnInfo(path.New("json", "named"), errDummy)
+	// or whose pointers do, addressable or not
+	expected.AddOnInfo(path.New("json", "label"), errDummy)
+	// Validate methods still run
+	expected.AddOnError(path.New("json", "test"), errDummy)
+
+	for i, v := range []interface{}{&in, in} {
+		actual := validate.ValidateCustom(v, "json", reg.Validator())
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("#%d: expected %+v got %+v", i, expected, actual)
+		}
+	}
+
+	// an empty registry only runs Validate methods
+	var empty validate.Registry
+	expected = report.Report{}
+	expected.AddOnError(path.New("json", "test"), errDummy)
+	actual := validate.ValidateCustom(in, "json", empty.Validator())
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
+	"github.com/coreos/vcontext/validate"
+)
+
+var (
+	errNoUsers = errors.New("no users")
+	errNoPath  = errors.New("no path")
+)
+
+func hasUsers(cfg Config, c path.ContextPath) (r report.Report) {
+	if len(cfg.Users) == 0 {
+		r.AddOnWarn(c.Append("users"), errNoUsers)
+	}
+	return
+}
+
+func hasPath(f File, c path.ContextPath) (r report.Report) {
+	if f.Path == "" {
+		r.AddOnError(c.Append("path"), errNoPath)
+	}
+	return
+}
+
+func notEmpty(s fmt.Stringer, c path.ContextPath) (r report.Report) {
+	if s.String() == "" {
+		r.AddOnInfo(c, errDummy)
+	}
+	return
+}
+
+func TestValidateWith(t *testing.T) {
+	in := Config{
+		Files: []File{{Path: "/a"}, {}},
+		Extra: map[string]Test3{"x": {}},
+	}
+
+	var expected report.Report
+	expected.AddOnWarn(path.New("json", "users"), errNoUsers)
+	actual := validate.ValidateWith(in, validate.Options{Tag: "json"}, hasUsers)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+
+	// rules for other types are run through a registry
+	var reg validate.Registry
+	validate.Register(&reg, hasPath)
+	expected.AddOnError(path.New("json", "files", 1, "path"), errNoPath)
+	actual = validate.ValidateWith(in, validate.Options{
+		Tag:       "json",
+		Validator: reg.Validator(),
+	}, hasUsers)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+
+	// rules for an interface are run on every value implementing it, or
+	// whose pointer does
+	hosts := []Host{{Named: Name("")}, {Named: Name("a"), Label: "b"}}
+	expected = report.Report{}
+	expected.AddOnInfo(path.New("json", 0, "named"), errDummy)
+	expected.AddOnInfo(path.New("json", 0, "label"), errDummy)
+	expected.AddOnError(path.New("json", 0, "test"), errDummy)
+	expected.AddOnError(path.New("json", 1, "test"), errDummy)
+	actual = validate.ValidateWithOptions(hosts, validate.Options{
+		Tag:       "json",
+		Validator: validate.Compose(validate.DefaultValidator, validate.Rule[fmt.Stringer](notEmpty).Validator()),
+	})
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+}
+
+func TestAll(t *testing.T) {
+	rule := validate.All(hasUsers, func(cfg Config, c path.ContextPath) (r report.Report) {
+		for i, f := range cfg.Files {
+			r.Merge(hasPath(f, c.Append("files", i)))
+		}
+		return
+	})
+	var expected report.Report
+	expected.AddOnWarn(path.New("json", "users"), errNoUsers)
+	expected.AddOnError(path.New("json", "files", 0, "path"), errNoPath)
+
+	actual := rule(Config{Files: []File{{}}}, path.New("json"))
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+}
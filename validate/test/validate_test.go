@@ -15,6 +15,7 @@
 package validate
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
@@ -74,6 +75,31 @@ type Test7 struct {
 	Bar []*Test2 `yaml:"yaml" json:"json"`
 }
 
+// Test8 has a Validate method with a pointer receiver.
+type Test8 struct {
+	calls int
+}
+
+func (t *Test8) Validate(c path.ContextPath) (r report.Report) {
+	t.calls++
+	r.AddOnError(c, errDummy)
+	return
+}
+
+type Test9 struct {
+	Foo Test8    `json:"foo"`
+	Bar []Test8  `json:"bar"`
+	Baz *Test8   `json:"baz"`
+	Qux []*Test2 `json:"qux"`
+}
+
+type Anything interface{}
+
+// Test10 embeds an interface, whose fields are those of the value it holds.
+type Test10 struct {
+	Anything
+}
+
 func TestValidate(t *testing.T) {
 	type test struct {
 		in  interface{}
@@ -173,6 +199,21 @@ func TestValidate(t *testing.T) {
 			src: "yaml",
 			out: fromSingleError([]interface{}{"yaml", 0}, errDummy),
 		},
+		{
+			in: Test10{},
+		},
+		{
+			in:  Test10{Anything: Test3{}},
+			out: fromSingleError([]interface{}{"Foo"}, errDummy),
+		},
+		{
+			in:  Test10{Anything: Test3{}},
+			src: "json",
+			out: fromSingleError([]interface{}{"json"}, errDummy),
+		},
+		{
+			in: Test10{Anything: &Test3{}},
+		},
 	}
 	for i, test := range tests {
 		expected := test.out
@@ -192,3 +233,398 @@ func TestValidate(t *testing.T) {
 		}
 	}
 }
+
+type User struct {
+	Name string `json:"name"`
+}
+
+type File struct {
+	Path  string  `json:"path"`
+	Owner *string `json:"owner"`
+}
+
+type Config struct {
+	Users []User           `json:"users"`
+	Files []File           `json:"files"`
+	Extra map[string]Test3 `json:"extra"`
+}
+
+type inner struct {
+	A string `json:"a"`
+}
+
+// Outer embeds an unexported struct, whose fields are still validated.
+type Outer struct {
+	inner
+	B string `json:"b"`
+}
+
+// checkOwners reports files owned by users which aren't defined.
+func checkOwners(root reflect.Value, c path.ContextPath, lookup validate.LookupFunc) (r report.Report) {
+	users := map[string]bool{}
+	if v, ok := lookup(c.Append("users")); ok {
+		for i := 0; i < v.Len(); i++ {
+			users[v.Index(i).Interface().(User).Name] = true
+		}
+	}
+	files, ok := lookup(c.Append("files"))
+	if !ok {
+		return
+	}
+	for i := 0; i < files.Len(); i++ {
+		owner, ok := lookup(c.Append("files", i, "owner"))
+		if ok && !users[owner.String()] {
+			r.AddOnError(c.Append("files", i, "owner"), errDummy)
+		}
+	}
+	return
+}
+
+func TestValidateWithOptions(t *testing.T) {
+	root := "root"
+	nobody := "nobody"
+	tests := []struct {
+		in  interface{}
+		out report.Report
+	}{
+		{
+			in: Config{},
+		},
+		{
+			in: &Config{
+				Users: []User{{Name: "root"}},
+				Files: []File{
+					{Path: "/a", Owner: &root},
+					{Path: "/b"},
+					{Path: "/c", Owner: &nobody},
+				},
+			},
+			out: fromSingleError([]interface{}{"files", 2, "owner"}, errDummy),
+		},
+		{
+			in: Config{
+				Files: []File{{Path: "/a", Owner: &root}},
+			},
+			out: fromSingleError([]interface{}{"files", 0, "owner"}, errDummy),
+		},
+	}
+
+	for i, test := range tests {
+		for j := range test.out.Entries {
+			test.out.Entries[j].Context.Tag = "json"
+		}
+		actual := validate.ValidateWithOptions(test.in, validate.Options{
+			Tag:   "json",
+			Rules: []validate.DocumentRule{checkOwners},
+		})
+		if !reflect.DeepEqual(test.out, actual) {
+			t.Errorf("#%d: expected %+v got %+v", i, test.out, actual)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	root := "root"
+	in := &Config{
+		Users: []User{{Name: "root"}},
+		Files: []File{{Path: "/a", Owner: &root}},
+		Extra: map[string]Test3{"x": {}},
+	}
+	tests := []struct {
+		in  path.ContextPath
+		tag string
+		out interface{}
+	}{
+		{
+			in:  path.New("json"),
+			tag: "json",
+			out: *in,
+		},
+		{
+			in:  path.New("json", "files", 0, "owner"),
+			tag: "json",
+			out: "root",
+		},
+		{
+			in:  path.New("", "Files", 0, "Path"),
+			out: "/a",
+		},
+		{
+			in:  path.New("json", "extra", "x", "json"),
+			tag: "json",
+			out: Test2{},
+		},
+		{
+			in:  path.New("json", "files", 1),
+			tag: "json",
+		},
+		{
+			in:  path.New("json", "extra", "y"),
+			tag: "json",
+		},
+		{
+			in:  path.New("json", "users", "name"),
+			tag: "json",
+		},
+		{
+			in:  path.New("json", "Users"),
+			tag: "json",
+		},
+	}
+
+	for i, test := range tests {
+		v, ok := validate.Lookup(reflect.ValueOf(in), test.in, test.tag)
+		if ok != (test.out != nil) {
+			t.Errorf("#%d: expected found %v, got %v", i, test.out != nil, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(v.Interface(), test.out) {
+			t.Errorf("#%d: expected %+v, got %+v", i, test.out, v.Interface())
+		}
+	}
+
+	// embedded structs and interfaces are looked through like the walker
+	// does
+	embedded := []struct {
+		root interface{}
+		in   path.ContextPath
+		out  interface{}
+	}{
+		{Outer{inner{A: "x"}, "y"}, path.New("json", "a"), "x"},
+		{Outer{inner{A: "x"}, "y"}, path.New("json", "b"), "y"},
+		{Outer{}, path.New("json", "inner"), nil},
+		{Test10{Anything: User{Name: "root"}}, path.New("json", "name"), "root"},
+		{Test10{}, path.New("json", "name"), nil},
+	}
+	for i, test := range embedded {
+		v, ok := validate.Lookup(reflect.ValueOf(test.root), test.in, "json")
+		if ok != (test.out != nil) {
+			t.Errorf("embedded #%d: expected found %v, got %v", i, test.out != nil, ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(v.Interface(), test.out) {
+			t.Errorf("embedded #%d: expected %+v, got %+v", i, test.out, v.Interface())
+		}
+	}
+}
+
+func TestValidatePointerReceiver(t *testing.T) {
+	in := Test9{
+		Bar: []Test8{{}, {}},
+		Baz: &Test8{},
+		Qux: []*Test2{{}},
+	}
+	var expected report.Report
+	for _, c := range []path.ContextPath{
+		path.New("json", "foo"),
+		path.New("json", "bar", 0),
+		path.New("json", "bar", 1),
+		path.New("json", "baz"),
+		path.New("json", "qux", 0),
+	} {
+		expected.AddOnError(c, errDummy)
+	}
+
+	// fields of values reached through a pointer are addressable, so
+	// Validate is called on them directly
+	actual := validate.Validate(&in, "json")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+	for i, calls := range []int{in.Foo.calls, in.Bar[0].calls, in.Bar[1].calls, in.Baz.calls} {
+		if calls != 1 {
+			t.Errorf("#%d: expected Validate to be called once, got %d", i, calls)
+		}
+	}
+
+	// otherwise, it is called on a copy
+	actual = validate.Validate(in, "json")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+	if in.Foo.calls != 1 || in.Baz.calls != 2 {
+		t.Errorf("expected only values behind pointers to be validated in place, got %d and %d calls", in.Foo.calls, in.Baz.calls)
+	}
+}
+
+type Link struct {
+	Next *Link `json:"next"`
+	Test Test2 `json:"test"`
+}
+
+func TestValidateCycle(t *testing.T) {
+	a := &Link{}
+	b := &Link{Next: a}
+	a.Next = b
+
+	var expected report.Report
+	expected.AddOnWarn(path.New("json", "next", "next"), report.WithRelated(validate.ErrCycle, report.Related{
+		Context: path.New("json"),
+		Note:    "value first validated here",
+	}))
+	expected.AddOnError(path.New("json", "next", "test"), errDummy)
+	expected.AddOnError(path.New("json", "test"), errDummy)
+
+	actual := validate.Validate(a, "json")
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %+v got %+v", expected, actual)
+	}
+
+	// the same pointer reached twice without a cycle is validated twice
+	shared := &Link{}
+	in := []*Link{shared, shared}
+	if r := validate.Validate(in, "json"); len(r.Entries) != 2 {
+		t.Errorf("expected 2 entries, got %+v", r)
+	}
+}
+
+func TestValidateMaxDepth(t *testing.T) {
+	var head *Link
+	for i := 0; i < 20000; i++ {
+		head = &Link{Next: head}
+	}
+
+	tests := []struct {
+		maxDepth int
+		entries  int
+	}{
+		// Next and Test over the limit at depth 6, and Test at 4 and 2
+		{
+			maxDepth: 5,
+			entries:  4,
+		},
+		// the link over the limit at depth 10001, and a Test at every even
+		// depth; stopped instead of overflowing the stack
+		{
+			entries: validate.DefaultMaxDepth/2 + 1,
+		},
+	}
+
+	for i, test := range tests {
+		r := validate.ValidateWithOptions(head, validate.Options{
+			Tag:      "json",
+			MaxDepth: test.maxDepth,
+		})
+		if len(r.Entries) != test.entries {
+			t.Errorf("#%d: expected %d entries, got %d", i, test.entries, len(r.Entries))
+			continue
+		}
+		if !errors.Is(r.Entries[0], validate.ErrMaxDepth) {
+			t.Errorf("#%d: expected depth error, got %v", i, r.Entries[0])
+		}
+	}
+}
+
+// Canceler cancels the validation when it is validated, if cancel is set.
+type Canceler struct {
+	cancel func()
+}
+
+func (t Canceler) ValidateContext(ctx context.Context, c path.ContextPath) (r report.Report) {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	r.AddOnError(c, errDummy)
+	return
+}
+
+// Validate is not called, since ValidateContext is preferred.
+func (t Canceler) Validate(c path.ContextPath) (r report.Report) {
+	r.AddOnError(c, errors.New("called Validate"))
+	return
+}
+
+func TestValidateContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := []Canceler{{}, {cancel: cancel}, {}}
+
+	r := validate.ValidateContext(ctx, in, "json")
+	if !r.Incomplete {
+		t.Errorf("report is not marked incomplete")
+	}
+	if len(r.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", r)
+	}
+	for i, e := range r.Entries[:2] {
+		if !e.Context.Equal(path.New("json", i)) || !errors.Is(e, errDummy) {
+			t.Errorf("#%d: expected ValidateContext error at %v, got %v", i, path.New("json", i), e)
+		}
+	}
+	// the walk stops at the next value, within the canceler
+	if e := r.Entries[2]; !e.Context.HasPrefix(path.New("json", 1)) || !errors.Is(e, context.Canceled) {
+		t.Errorf("expected cancellation error within $.1, got %v", e)
+	}
+
+	// concurrent validation stops at the first element stopped too
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	in = []Canceler{{}, {cancel: cancel}, {}, {}}
+	r = validate.ValidateWithOptionsContext(ctx, in, validate.Options{
+		Workers: 4,
+	})
+	if last := r.Entries[len(r.Entries)-1]; !r.Incomplete || !errors.Is(last, context.Canceled) {
+		t.Errorf("expected an incomplete report ending with a cancellation error, got %+v", r)
+	}
+
+	// nothing is validated with a context which is already done, not even
+	// the document rules
+	called := false
+	r = validate.ValidateWithOptionsContext(ctx, Test2{}, validate.Options{
+		Rules: []validate.DocumentRule{
+			func(root reflect.Value, c path.ContextPath, lookup validate.LookupFunc) (r report.Report) {
+				called = true
+				return
+			},
+		},
+	})
+	if !r.Incomplete || len(r.Entries) != 1 || !errors.Is(r.Entries[0], context.Canceled) || called {
+		t.Errorf("expected only a cancellation error, got %+v", r)
+	}
+
+	// without a context, ValidateContext methods are passed a background one
+	r = validate.Validate([]Canceler{{}}, "json")
+	if r.Incomplete || len(r.Entries) != 1 || !errors.Is(r.Entries[0], errDummy) {
+		t.Errorf("expected a single dummy error, got %+v", r)
+	}
+}
+
+// Item is invalid if N is odd.
+type Item struct {
+	N    int    `json:"n"`
+	Subs []Item `json:"subs"`
+}
+
+func (t Item) Validate(c path.ContextPath) (r report.Report) {
+	if t.N%2 != 0 {
+		r.AddOnError(c.Append("n"), errDummy)
+	}
+	return
+}
+
+func TestValidateWorkers(t *testing.T) {
+	in := make([][]Item, 20)
+	for i := range in {
+		for j := 0; j < 100; j++ {
+			in[i] = append(in[i], Item{
+				N:    i + j,
+				Subs: []Item{{N: j}, {N: j + 1}},
+			})
+		}
+	}
+	// half the items and one sub of each are invalid
+	expected := validate.Validate(in, "json")
+	if len(expected.Entries) != 20*100*3/2 {
+		t.Fatalf("expected %d entries, got %d", 20*100*3/2, len(expected.Entries))
+	}
+
+	for _, workers := range []int{1, 2, 8, 100} {
+		actual := validate.ValidateWithOptions(in, validate.Options{
+			Tag:     "json",
+			Workers: workers,
+		})
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("%d workers: report differs from serial validation", workers)
+		}
+	}
+}
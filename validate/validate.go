@@ -15,13 +15,26 @@
 package validate
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/coreos/vcontext/path"
 	"github.com/coreos/vcontext/report"
 )
 
+// DefaultMaxDepth is the depth ValidateWithOptions stops descending at if
+// Options.MaxDepth is 0.
+const DefaultMaxDepth = 10000
+
+var (
+	ErrCycle    = errors.New("value refers back to itself; not validating it again")
+	ErrMaxDepth = errors.New("value is nested too deeply; not validating it")
+)
+
 type CustomValidator func(v reflect.Value, c path.ContextPath) report.Report
 
 // validator is the interface the DefaultValidator function uses when validating types.
@@ -30,37 +43,306 @@ type validator interface {
 	Validate(path.ContextPath) report.Report
 }
 
+// contextValidator is the interface the DefaultContextValidator function uses in
+// preference to validator. Implement it on types whose validation is slow enough
+// that it should stop when the validation is canceled.
+type contextValidator interface {
+	ValidateContext(context.Context, path.ContextPath) report.Report
+}
+
+var (
+	validatorType        = reflect.TypeOf((*validator)(nil)).Elem()
+	contextValidatorType = reflect.TypeOf((*contextValidator)(nil)).Elem()
+)
+
+// ContextValidator is like CustomValidator, but is also passed the context the
+// validation was started with.
+type ContextValidator func(ctx context.Context, v reflect.Value, c path.ContextPath) report.Report
+
 // DefaultValidator checks if the type implements the validator interface and calls the
-// validate function if it does, returning the report.
+// validate function if it does, returning the report. Validate methods with pointer
+// receivers are called on the value's address if it is addressable, and on a copy
+// otherwise.
 func DefaultValidator(v reflect.Value, c path.ContextPath) report.Report {
-	// first check if this object has Validate(context) defined, but only on value
-	// recievers. Both pointer and value receivers satisfy a value receiver interface
-	// so ensure we're not a pointer too.
-	if obj, ok := v.Interface().(validator); ok && v.Kind() != reflect.Ptr {
-		return obj.Validate(c)
+	m := plans.methodsOf(v.Type())
+	if obj, ok := implementation(v, m.validate, m.validatePtr); ok {
+		return obj.(validator).Validate(c)
 	}
 	return report.Report{}
 }
 
+// DefaultContextValidator is like DefaultValidator, but calls
+// ValidateContext(context.Context, path.ContextPath) report.Report methods with ctx
+// instead of Validate if the type has one.
+func DefaultContextValidator(ctx context.Context, v reflect.Value, c path.ContextPath) report.Report {
+	m := plans.methodsOf(v.Type())
+	if obj, ok := implementation(v, m.validateContext, m.validateContextPtr); ok {
+		return obj.(contextValidator).ValidateContext(ctx, c)
+	}
+	return DefaultValidator(v, c)
+}
+
+// implementation returns v if onValue is set, or else its address or a pointer to
+// a copy of it if onPtr is set, i.e. whichever has the method the flags are for.
+func implementation(v reflect.Value, onValue, onPtr bool) (interface{}, bool) {
+	// Pointers never have the methods in their methodPlan, since the walker
+	// visits what they point to next and both pointer and value receivers satisfy
+	// a value receiver interface. Calling methods on the value only ensures they
+	// are called exactly once.
+	if onValue {
+		return v.Interface(), true
+	}
+	if onPtr {
+		if v.CanAddr() {
+			return v.Addr().Interface(), true
+		}
+		cp := reflect.New(v.Type())
+		cp.Elem().Set(v)
+		return cp.Interface(), true
+	}
+	return nil, false
+}
+
+// LookupFunc returns the value at c in the document being validated, and
+// whether it exists. Pointers and interfaces along the way are followed.
+type LookupFunc func(c path.ContextPath) (reflect.Value, bool)
+
+// DocumentRule validates a whole document, e.g. to check that references
+// between fields resolve. It is passed the value given to
+// ValidateWithOptions, the document's (empty) context and a function to
+// look up values by path. Entries should be reported at the path of the
+// value at fault, e.g. the referencing field.
+type DocumentRule func(root reflect.Value, c path.ContextPath, lookup LookupFunc) report.Report
+
+// Options configures ValidateWithOptions.
+type Options struct {
+	// Tag is the struct tag used to name fields in paths, e.g. "json". If
+	// empty, the Go field names are used.
+	Tag string
+	// Validator is called on every value. If nil, DefaultContextValidator is
+	// used.
+	Validator CustomValidator
+	// ContextValidator is used instead of Validator if set.
+	ContextValidator ContextValidator
+	// Rules are run on the whole document after every value has been
+	// validated.
+	Rules []DocumentRule
+	// MaxDepth is the number of nested structs, slices and pointers
+	// validated before giving up with an ErrMaxDepth error. If 0,
+	// DefaultMaxDepth is used.
+	MaxDepth int
+	// Workers is the maximum number of goroutines validating the elements
+	// of slices concurrently, including the calling one. If it is more than
+	// 1, validators must be safe to call concurrently. The report is the
+	// same as when validating serially.
+	Workers int
+}
+
+// validator returns the validator to call on every value.
+func (opts Options) validator() ContextValidator {
+	switch {
+	case opts.ContextValidator != nil:
+		return opts.ContextValidator
+	case opts.Validator != nil:
+		return func(_ context.Context, v reflect.Value, c path.ContextPath) report.Report {
+			return opts.Validator(v, c)
+		}
+	default:
+		return DefaultContextValidator
+	}
+}
+
 // ValidateCustom validates thing using the custom validation function supplied. Most users will not need this
 // and should use Validate() instead.
 func ValidateCustom(thing interface{}, tag string, customValidator CustomValidator) report.Report {
+	return ValidateWithOptions(thing, Options{
+		Tag:       tag,
+		Validator: customValidator,
+	})
+}
+
+// ValidateContext is like Validate, but stops when ctx is done. The returned
+// report is then marked incomplete and has an error wrapping ctx.Err() at the
+// path validation stopped at.
+func ValidateContext(ctx context.Context, thing interface{}, tag string) report.Report {
+	return ValidateWithOptionsContext(ctx, thing, Options{
+		Tag: tag,
+	})
+}
+
+// ValidateWithOptions validates thing like Validate, then runs the document
+// rules in opts.
+func ValidateWithOptions(thing interface{}, opts Options) report.Report {
+	return ValidateWithOptionsContext(context.Background(), thing, opts)
+}
+
+// ValidateWithOptionsContext is like ValidateWithOptions, but stops when ctx is
+// done, like ValidateContext.
+func ValidateWithOptionsContext(ctx context.Context, thing interface{}, opts Options) (r report.Report) {
 	if thing == nil {
-		return report.Report{}
+		return
+	}
+	w := walker{
+		ctx:      ctx,
+		f:        opts.validator(),
+		maxDepth: opts.MaxDepth,
+	}
+	if w.maxDepth == 0 {
+		w.maxDepth = DefaultMaxDepth
+	}
+	if opts.Workers > 1 {
+		w.sem = make(chan struct{}, opts.Workers-1)
 	}
 	v := reflect.ValueOf(thing)
-	ctx := path.ContextPath{Tag: tag}
-	return validate(ctx, v, customValidator)
+	c := path.ContextPath{Tag: opts.Tag}
+	w.validate(&r, c, v, 0, nil)
+
+	lookup := func(c path.ContextPath) (reflect.Value, bool) {
+		return Lookup(v, c, opts.Tag)
+	}
+	for _, rule := range opts.Rules {
+		if w.stopped(&r, c) {
+			break
+		}
+		r.Merge(rule(v, c, lookup))
+	}
+	return
+}
+
+// Lookup returns the value at c in v, and whether it exists. Struct fields
+// are named as by FieldName with tag, slices and arrays are indexed by int
+// and maps with string keys by string. Pointers and interfaces along the
+// way are followed, and so is the value returned.
+func Lookup(v reflect.Value, c path.ContextPath, tag string) (reflect.Value, bool) {
+	v = indirect(v)
+	for _, e := range c.Path {
+		switch v.Kind() {
+		case reflect.Struct:
+			name, ok := e.(string)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			if v, ok = structField(v, name, tag); !ok {
+				return reflect.Value{}, false
+			}
+		case reflect.Slice, reflect.Array:
+			i, ok := e.(int)
+			if !ok || i < 0 || i >= v.Len() {
+				return reflect.Value{}, false
+			}
+			v = v.Index(i)
+		case reflect.Map:
+			key, ok := e.(string)
+			if !ok || v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, false
+			}
+			v = v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key()))
+		default:
+			return reflect.Value{}, false
+		}
+		v = indirect(v)
+	}
+	return v, v.IsValid()
+}
+
+// structField returns the field of v named name, looking through embedded
+// structs and interfaces the same way the walker does.
+func structField(v reflect.Value, name, tag string) (reflect.Value, bool) {
+	for _, field := range plans.fieldsOf(v.Type(), tag) {
+		fieldValue := v.FieldByIndex(field.index)
+		if field.dynamic {
+			if concrete := makeConcrete(fieldValue); concrete.Kind() == reflect.Struct {
+				if ret, ok := structField(concrete, name, tag); ok {
+					return ret, true
+				}
+			}
+			continue
+		}
+		if field.name == name {
+			return fieldValue, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// indirect follows pointers and interfaces until it reaches a concrete
+// value, or returns the zero Value if it reaches nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
 }
 
 // Validate walks the structs, slices, and pointers in thing and calls any Validate(path.ContextPath) report.Report
-// functions defined on the types, aggregating the results.
+// functions defined on the types, aggregating the results. Types with a
+// ValidateContext(context.Context, path.ContextPath) report.Report method have it called instead, with a
+// background context.
 func Validate(thing interface{}, tag string) report.Report {
-	return ValidateCustom(thing, tag, DefaultValidator)
+	return ValidateWithOptions(thing, Options{
+		Tag: tag,
+	})
 }
 
-func validate(context path.ContextPath, v reflect.Value, validateFunc CustomValidator) (r report.Report) {
-	if !v.IsValid() {
+// walker holds the state of a call to ValidateWithOptions.
+type walker struct {
+	ctx      context.Context
+	f        ContextValidator
+	maxDepth int
+	// sem holds a token for each goroutine started to validate slice
+	// elements. It is nil when validating serially.
+	sem chan struct{}
+}
+
+// stopped returns true if the validation should stop because it is
+// incomplete or its context is done. The first time the context is found to
+// be done, r is marked incomplete and the context's error is added at c.
+func (w walker) stopped(r *report.Report, c path.ContextPath) bool {
+	if r.Incomplete {
+		return true
+	}
+	select {
+	case <-w.ctx.Done():
+		r.AddOnError(c, fmt.Errorf("validation stopped: %w", w.ctx.Err()))
+		r.Incomplete = true
+		return true
+	default:
+		return false
+	}
+}
+
+// ancestor is a pointer followed to reach the value being validated.
+type ancestor struct {
+	ptr     uintptr
+	typ     reflect.Type
+	context path.ContextPath
+	parent  *ancestor
+}
+
+// find returns the ancestor of a which is the pointer v, if any. Pointers
+// of different types to the same address, like to a struct and its first
+// field, are different.
+func (a *ancestor) find(v reflect.Value) *ancestor {
+	for ; a != nil; a = a.parent {
+		if a.ptr == v.Pointer() && a.typ == v.Type() {
+			return a
+		}
+	}
+	return nil
+}
+
+// validate validates v and its descendants, adding the entries to r. The
+// entries are added to a single report, rather than merged up level by
+// level, so deeply nested values don't take quadratic time.
+func (w walker) validate(r *report.Report, context path.ContextPath, v reflect.Value, depth int, ancestors *ancestor) {
+	if !v.IsValid() || w.stopped(r, context) {
+		return
+	}
+	if depth > w.maxDepth {
+		r.AddOnError(context, fmt.Errorf("%w (more than %d levels)", ErrMaxDepth, w.maxDepth))
 		return
 	}
 	if v.Kind() == reflect.Interface {
@@ -71,20 +353,31 @@ func validate(context path.ContextPath, v reflect.Value, validateFunc CustomVali
 		}
 	}
 
-	r.Merge(validateFunc(v, context))
+	r.Merge(w.f(w.ctx, v, context))
 
 	switch v.Kind() {
 	case reflect.Struct:
-		r.Merge(validateStruct(context, v, validateFunc))
+		w.validateStruct(r, context, v, depth, ancestors)
 	case reflect.Slice:
-		r.Merge(validateSlice(context, v, validateFunc))
+		w.validateSlice(r, context, v, depth, ancestors)
 	case reflect.Ptr:
-		if !v.IsNil() {
-			r.Merge(validate(context, v.Elem(), validateFunc))
+		if v.IsNil() {
+			break
 		}
+		if a := ancestors.find(v); a != nil {
+			r.AddOnWarn(context, report.WithRelated(ErrCycle, report.Related{
+				Context: a.context,
+				Note:    "value first validated here",
+			}))
+			break
+		}
+		w.validate(r, context, v.Elem(), depth+1, &ancestor{
+			ptr:     v.Pointer(),
+			typ:     v.Type(),
+			context: context,
+			parent:  ancestors,
+		})
 	}
-
-	return
 }
 
 // StructField is an extension of go's reflect.StructField that also includes the value.
@@ -131,19 +424,63 @@ func FieldName(s StructField, tag string) string {
 	return strings.Split(tag, ",")[0]
 }
 
-func validateStruct(context path.ContextPath, v reflect.Value, f CustomValidator) (r report.Report) {
-	fields := GetFields(v)
-	for _, field := range fields {
-		fieldContext := context.Append(FieldName(field, context.Tag))
-		r.Merge(validate(fieldContext, field.Value, f))
+// validateStruct validates the fields of v as flattened by GetFields.
+func (w walker) validateStruct(r *report.Report, context path.ContextPath, v reflect.Value, depth int, ancestors *ancestor) {
+	for _, field := range plans.fieldsOf(v.Type(), context.Tag) {
+		fieldValue := v.FieldByIndex(field.index)
+		if field.dynamic {
+			if concrete := makeConcrete(fieldValue); concrete.Kind() == reflect.Struct {
+				w.validateStruct(r, context, concrete, depth, ancestors)
+			}
+			continue
+		}
+		fieldContext := context.Append(field.name)
+		w.validate(r, fieldContext, fieldValue, depth+1, ancestors)
 	}
-	return
 }
 
-func validateSlice(context path.ContextPath, v reflect.Value, f CustomValidator) (r report.Report) {
+func (w walker) validateSlice(r *report.Report, context path.ContextPath, v reflect.Value, depth int, ancestors *ancestor) {
+	if w.sem != nil && v.Len() > 1 {
+		w.validateSliceConcurrently(r, context, v, depth, ancestors)
+		return
+	}
 	for i := 0; i < v.Len(); i++ {
 		childContext := context.Append(i)
-		r.Merge(validate(childContext, v.Index(i), f))
+		w.validate(r, childContext, v.Index(i), depth+1, ancestors)
+	}
+}
+
+// validateSliceConcurrently validates the elements of v in new goroutines
+// while there are workers to spare, and in the calling one otherwise, so
+// nested slices can't deadlock waiting for workers. The reports are merged
+// in order to match validating serially.
+func (w walker) validateSliceConcurrently(r *report.Report, context path.ContextPath, v reflect.Value, depth int, ancestors *ancestor) {
+	reports := make([]report.Report, v.Len())
+	var wg sync.WaitGroup
+	for i := 0; i < v.Len(); i++ {
+		// Append may reuse the path's underlying array, so each
+		// element needs its own copy
+		childContext := context.Copy().Append(i)
+		select {
+		case w.sem <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-w.sem
+					wg.Done()
+				}()
+				w.validate(&reports[i], childContext, v.Index(i), depth+1, ancestors)
+			}(i)
+		default:
+			w.validate(&reports[i], childContext, v.Index(i), depth+1, ancestors)
+		}
+	}
+	wg.Wait()
+	for _, child := range reports {
+		r.Merge(child)
+		// a serial walk would have stopped here
+		if r.Incomplete {
+			break
+		}
 	}
-	return
 }
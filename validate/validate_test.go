@@ -15,8 +15,12 @@
 package validate
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/report"
 )
 
 func TestGetFields(t *testing.T) {
@@ -221,3 +225,65 @@ func TestGetFields(t *testing.T) {
 		}
 	}
 }
+
+type benchUser struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups"`
+	Shell  *string  `json:"shell"`
+}
+
+func (u benchUser) Validate(c path.ContextPath) (r report.Report) {
+	if u.Name == "" {
+		r.AddOnError(c.Append("name"), errors.New("empty name"))
+	}
+	return
+}
+
+type benchFile struct {
+	Path string `json:"path"`
+	Mode *int   `json:"mode"`
+}
+
+type benchConfig struct {
+	Version string      `json:"version"`
+	Users   []benchUser `json:"users"`
+	Files   []benchFile `json:"files"`
+}
+
+func benchmarkConfig() benchConfig {
+	shell := "/bin/sh"
+	mode := 0644
+	return benchConfig{
+		Version: "1.0.0",
+		Users: []benchUser{
+			{Name: "core", Groups: []string{"wheel", "sudo"}, Shell: &shell},
+			{Name: "", Groups: []string{"docker"}},
+		},
+		Files: []benchFile{
+			{Path: "/etc/hostname", Mode: &mode},
+			{Path: "/etc/motd"},
+		},
+	}
+}
+
+// BenchmarkValidate compares validating a small config with the per-type
+// plans cached across calls, as normal, to rebuilding them every call.
+func BenchmarkValidate(b *testing.B) {
+	in := benchmarkConfig()
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Validate(in, "json")
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		defer func(p *planCache) {
+			plans = p
+		}(plans)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			plans = new(planCache)
+			Validate(in, "json")
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package json
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/coreos/vcontext/tree"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal returns the yaml encoding of the tree n. Maps are written with
+// their keys in source order (see tree.MapNode.OrderedKeys), and the
+// comments, styles and scalar text recorded in each node's Format are
+// preserved.
+// Indentation and other layout is normalized.
+func Marshal(n tree.Node) ([]byte, error) {
+	y, err := toYamlNode(n)
+	if err != nil {
+		return nil, err
+	}
+	// UnmarshalToContext moves the document's comments, which are
+	// separated from the content by blank lines, onto the root node
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		HeadComment: y.HeadComment,
+		FootComment: y.FootComment,
+		Content:     []*yaml.Node{y},
+	}
+	y.HeadComment = ""
+	y.FootComment = ""
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toYamlNode(n tree.Node) (*yaml.Node, error) {
+	var ret *yaml.Node
+	switch v := n.(type) {
+	case nil:
+		return &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Tag:   "!!null",
+			Value: "null",
+		}, nil
+	case tree.MapNode:
+		ret = &yaml.Node{
+			Kind: yaml.MappingNode,
+		}
+		for _, k := range v.OrderedKeys() {
+			child, ok := v.Children[k]
+			if !ok {
+				continue
+			}
+			key := &yaml.Node{
+				Kind:  yaml.ScalarNode,
+				Tag:   "!!str",
+				Value: k,
+			}
+			applyFormat(key, v.Keys[k].Format)
+			value, err := toYamlNode(child)
+			if err != nil {
+				return nil, err
+			}
+			ret.Content = append(ret.Content, key, value)
+		}
+		applyFormat(ret, v.Format)
+	case tree.SliceNode:
+		ret = &yaml.Node{
+			Kind: yaml.SequenceNode,
+		}
+		for _, child := range v.Children {
+			value, err := toYamlNode(child)
+			if err != nil {
+				return nil, err
+			}
+			ret.Content = append(ret.Content, value)
+		}
+		applyFormat(ret, v.Format)
+	case tree.Leaf:
+		if ret = fromText(v.Format, v.Value); ret == nil {
+			ret = &yaml.Node{}
+			if err := ret.Encode(v.Value); err != nil {
+				return nil, err
+			}
+		}
+		applyFormat(ret, v.Format)
+	default:
+		return nil, fmt.Errorf("cannot marshal node of type %T", n)
+	}
+	return ret, nil
+}
+
+// fromText returns a scalar node with f's text and tag, or nil if they
+// don't encode value, e.g. because the leaf was edited.
+func fromText(f tree.Format, value interface{}) *yaml.Node {
+	if f.Tag == "" {
+		return nil
+	}
+	ret := &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   f.Tag,
+		Value: f.Text,
+	}
+	var v interface{}
+	if err := ret.Decode(&v); err != nil || !reflect.DeepEqual(v, value) {
+		return nil
+	}
+	return ret
+}
+
+func applyFormat(n *yaml.Node, f tree.Format) {
+	n.HeadComment = f.Comments.Head
+	n.LineComment = f.Comments.Line
+	n.FootComment = f.Comments.Foot
+	switch f.Style {
+	case tree.FlowStyle:
+		n.Style = yaml.FlowStyle
+	case tree.SingleQuotedStyle:
+		n.Style = yaml.SingleQuotedStyle
+	case tree.DoubleQuotedStyle:
+		n.Style = yaml.DoubleQuotedStyle
+	case tree.LiteralStyle:
+		n.Style = yaml.LiteralStyle
+	case tree.FoldedStyle:
+		n.Style = yaml.FoldedStyle
+	}
+	// quoting styles only make sense for strings
+	if n.Kind == yaml.ScalarNode && n.Tag != "!!str" && f.Style != tree.DefaultStyle {
+		n.Style = 0
+	}
+}
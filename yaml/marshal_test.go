@@ -0,0 +1,105 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package json
+
+import (
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+	}{
+		{"a: 1\n", "a: 1\n"},
+		// key order, comments and styles are preserved
+		{
+			in: `# header
+
+z: 1 # line
+# head of a
+a:
+  - 'single'
+  - "double"
+  - [1, 2]
+  - {x: z}
+contents: |
+  line 1
+  line 2
+b: null
+`,
+		},
+		// scalars are written as they were
+		{
+			in: "a: 0o17\nb: 2001-12-14\nc: 12345678901234567890\nd: 1.0\ne: ~\nf: yes\n",
+		},
+		// layout is normalized
+		{
+			in:  "a:\n    - 1\n    -    2\n",
+			out: "a:\n  - 1\n  - 2\n",
+		},
+	}
+
+	for i, test := range tests {
+		if test.out == "" {
+			test.out = test.in
+		}
+		n, err := UnmarshalToContext([]byte(test.in))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		out, err := Marshal(n)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if string(out) != test.out {
+			t.Errorf("#%d: expected\n%s\ngot\n%s", i, test.out, out)
+		}
+	}
+}
+
+func TestMarshalEdited(t *testing.T) {
+	n, err := UnmarshalToContext([]byte("b: 1 # keep me\na: 2\nd: 0o17\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// edited values don't keep their text
+	d, err := n.Get(path.New("", "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := d.(tree.Leaf)
+	leaf.Value = 16
+	if n, err = tree.Set(n, path.New("", "d"), leaf); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = tree.Set(n, path.New("", "c"), tree.Leaf{Value: []interface{}{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err = tree.Delete(n, path.New("", "a")); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "b: 1 # keep me\nd: 16\nc:\n  - x\n"
+	if string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+}
@@ -15,26 +15,108 @@
 package json
 
 import (
+	"bytes"
+
 	"github.com/coreos/vcontext/tree"
 
 	"gopkg.in/yaml.v3"
 )
 
-func UnmarshalToContext(raw []byte) (tree.Node, error) {
+func UnmarshalToContext(raw []byte, opts ...tree.Option) (tree.Node, error) {
+	o := tree.NewOptions(opts...)
 	var ast yaml.Node
 	if err := yaml.Unmarshal(raw, &ast); err != nil {
 		return nil, err
 	}
-	return fromYamlNode(ast), nil
+	// yaml reports columns in runes; work out the offsets so the markers
+	// are as complete as json's, and recount the columns if asked to.
+	runes := tree.ColumnOptions{Mode: tree.RuneColumns}
+	p := parser{
+		raw:    raw,
+		lines:  tree.NewLineIndex(raw, runes),
+		source: o.Source,
+	}
+	node := p.fromYamlNode(ast)
+	if node == nil {
+		return nil, nil
+	}
+	if cols := o.ColumnsOr(runes); cols != runes {
+		tree.FixLineColumnWithOptions(node, raw, cols)
+	}
+	return node, nil
 }
 
-func fromYamlNode(n yaml.Node) tree.Node {
+type parser struct {
+	raw    []byte
+	lines  *tree.LineIndex
+	source string
+}
+
+// marker returns the marker of n. yaml only records where nodes start, so
+// only scalars written on a single line, whose end is easy to find, get an
+// EndP.
+func (p parser) marker(n yaml.Node) tree.Marker {
+	start := &tree.Pos{
+		Line:   int64(n.Line),
+		Column: int64(n.Column),
+	}
 	m := tree.Marker{
-		StartP: &tree.Pos{
-			Line:   int64(n.Line),
-			Column: int64(n.Column),
-		},
+		StartP: start,
+		Source: p.source,
+	}
+	offset, err := p.lines.Offset(start.Line, start.Column)
+	if err != nil {
+		return m
+	}
+	start.Index = offset
+	if n.Kind != yaml.ScalarNode {
+		return m
+	}
+	if end, ok := scalarEnd(p.raw, offset, n); ok {
+		if pos, err := p.lines.Position(end); err == nil {
+			m.EndP = &pos
+		}
+	}
+	return m
+}
+
+// scalarEnd returns the offset of the last byte of the scalar n starting
+// at offset start in raw.
+func scalarEnd(raw []byte, start int64, n yaml.Node) (int64, bool) {
+	text := raw[start:]
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + int64(i), true
+			}
+		}
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		for i := 1; i < len(text); i++ {
+			if text[i] != '\'' {
+				continue
+			}
+			if i+1 < len(text) && text[i+1] == '\'' {
+				// an escaped quote
+				i++
+				continue
+			}
+			return start + int64(i), true
+		}
+	case n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0:
+		// plain scalars spanning several lines are folded, and tags or
+		// anchors precede the value, so neither matches the source
+		if n.Value != "" && bytes.HasPrefix(text, []byte(n.Value)) {
+			return start + int64(len(n.Value)) - 1, true
+		}
 	}
+	return 0, false
+}
+
+func (p parser) fromYamlNode(n yaml.Node) tree.Node {
 	switch n.Kind {
 	case 0:
 		// empty
@@ -43,40 +125,85 @@ func fromYamlNode(n yaml.Node) tree.Node {
 		if len(n.Content) == 0 {
 			return nil
 		}
-		return fromYamlNode(*n.Content[0])
+		// keep comments separated from the content by a blank line
+		content := *n.Content[0]
+		content.HeadComment = joinComments(n.HeadComment, content.HeadComment)
+		content.FootComment = joinComments(content.FootComment, n.FootComment)
+		return p.fromYamlNode(content)
 	case yaml.MappingNode:
 		ret := tree.MapNode{
-			Marker:   m,
+			Marker:   p.marker(n),
 			Children: make(map[string]tree.Node, len(n.Content)/2),
 			Keys:     make(map[string]tree.Leaf, len(n.Content)/2),
+			Format:   format(n),
 		}
 		// MappingNodes list keys and values like [k, v, k, v...]
 		for i := 0; i < len(n.Content); i += 2 {
 			key := *n.Content[i]
 			value := *n.Content[i+1]
 			ret.Keys[key.Value] = tree.Leaf{
-				Marker: tree.Marker{
-					StartP: &tree.Pos{
-						Line:   int64(key.Line),
-						Column: int64(key.Column),
-					},
-				},
+				Marker: p.marker(key),
+				Format: format(key),
 			}
-			ret.Children[key.Value] = fromYamlNode(value)
+			ret.Children[key.Value] = p.fromYamlNode(value)
 		}
 		return ret
 	case yaml.SequenceNode:
 		ret := tree.SliceNode{
-			Marker:   m,
+			Marker:   p.marker(n),
 			Children: make([]tree.Node, 0, len(n.Content)),
+			Format:   format(n),
 		}
 		for _, child := range n.Content {
-			ret.Children = append(ret.Children, fromYamlNode(*child))
+			ret.Children = append(ret.Children, p.fromYamlNode(*child))
 		}
 		return ret
 	default: // scalars and aliases
+		var v interface{}
+		// the document already parsed, so this can't fail
+		_ = n.Decode(&v)
 		return tree.Leaf{
-			Marker: m,
+			Marker: p.marker(n),
+			Value:  v,
+			Format: format(n),
 		}
 	}
 }
+
+func format(n yaml.Node) tree.Format {
+	f := tree.Format{
+		Comments: tree.Comments{
+			Head: n.HeadComment,
+			Line: n.LineComment,
+			Foot: n.FootComment,
+		},
+	}
+	if n.Kind == yaml.ScalarNode {
+		f.Text = n.Value
+		f.Tag = n.Tag
+	}
+	switch {
+	case n.Style&yaml.FlowStyle != 0:
+		f.Style = tree.FlowStyle
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		f.Style = tree.SingleQuotedStyle
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		f.Style = tree.DoubleQuotedStyle
+	case n.Style&yaml.LiteralStyle != 0:
+		f.Style = tree.LiteralStyle
+	case n.Style&yaml.FoldedStyle != 0:
+		f.Style = tree.FoldedStyle
+	}
+	return f
+}
+
+func joinComments(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	default:
+		return a + "\n\n" + b
+	}
+}
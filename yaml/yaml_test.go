@@ -0,0 +1,142 @@
+// Copyright 2019 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.)
+
+package json
+
+import (
+	"testing"
+
+	"github.com/coreos/vcontext/path"
+	"github.com/coreos/vcontext/tree"
+)
+
+func TestUnmarshalWithSource(t *testing.T) {
+	n, err := UnmarshalToContext([]byte("foo:\n  - bar\n"), tree.WithSource("config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in  path.ContextPath
+		out string
+	}{
+		{path.New(""), "config.yaml:1:1"},
+		{path.New("", tree.Key("foo")), "config.yaml:1:1"},
+		{path.New("", "foo"), "config.yaml:2:3"},
+		{path.New("", "foo", 0), "config.yaml:2:5"},
+	}
+	for i, test := range tests {
+		child, err := n.Get(test.in)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if s := child.GetMarker().String(); s != test.out {
+			t.Errorf("#%d: expected %q, got %q", i, test.out, s)
+		}
+	}
+}
+
+func TestUnmarshalColumns(t *testing.T) {
+	src := []byte("a:\n  - \"😀ä\"\n  - x\n")
+	tests := []struct {
+		opts  []tree.Option
+		index int64
+		line  int64
+		col   int64
+	}{
+		// yaml's own columns count runes
+		{nil, 20, 3, 5},
+		{[]tree.Option{tree.WithColumns(tree.ColumnOptions{})}, 20, 3, 5},
+		{[]tree.Option{tree.WithColumns(tree.ColumnOptions{Mode: tree.UTF16Columns})}, 20, 3, 5},
+	}
+	for i, test := range tests {
+		n, err := UnmarshalToContext(src, test.opts...)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		x, err := n.Get(path.New("", "a", 1))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		p := x.GetMarker().StartP
+		if p.Index != test.index || p.Line != test.line || p.Column != test.col {
+			t.Errorf("#%d: expected %d %d:%d, got %+v", i, test.index, test.line, test.col, *p)
+		}
+	}
+
+	// columns following multibyte characters depend on the mode
+	src = []byte("- [\"😀ä\", x]\n")
+	for i, test := range []struct {
+		opts tree.ColumnOptions
+		col  int64
+	}{
+		{tree.ColumnOptions{}, 14},
+		{tree.ColumnOptions{Mode: tree.RuneColumns}, 10},
+		{tree.ColumnOptions{Mode: tree.UTF16Columns}, 11},
+	} {
+		n, err := UnmarshalToContext(src, tree.WithColumns(test.opts))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		x, err := n.Get(path.New("", 0, 1))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p := x.GetMarker().StartP; p.Index != 13 || p.Column != test.col {
+			t.Errorf("#%d: expected index 13 col %d, got %+v", i, test.col, *p)
+		}
+	}
+}
+
+func TestUnmarshalLineEndings(t *testing.T) {
+	// mixed \n, \r\n and \r endings; "😀" makes the byte, rune and UTF-16
+	// columns differ so recounting columns exercises the offsets
+	src := []byte("a: 1\r\nb:\n  - [😀, ä]\r  - x\r\n")
+	for i, test := range []struct {
+		opts  []tree.Option
+		col   int64
+		index int64
+		// column of the "ä" following "😀"
+		ucol int64
+	}{
+		{nil, 5, 28, 9},
+		{[]tree.Option{tree.WithColumns(tree.ColumnOptions{})}, 5, 28, 12},
+		{[]tree.Option{tree.WithColumns(tree.ColumnOptions{Mode: tree.UTF16Columns})}, 5, 28, 10},
+	} {
+		n, err := UnmarshalToContext(src, test.opts...)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		x, err := n.Get(path.New("", "b", 1))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p := x.GetMarker().StartP; p.Line != 4 || p.Column != test.col || p.Index != test.index {
+			t.Errorf("#%d: expected 4:%d at %d, got %+v", i, test.col, test.index, *p)
+		}
+		u, err := n.Get(path.New("", "b", 0, 1))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p := u.GetMarker().StartP; p.Line != 3 || p.Column != test.ucol || p.Index != 20 {
+			t.Errorf("#%d: expected 3:%d at 20, got %+v", i, test.ucol, *p)
+		}
+		a, err := n.Get(path.New("", "a"))
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p := a.GetMarker().StartP; p.Line != 1 || p.Column != 4 || p.Index != 3 {
+			t.Errorf("#%d: expected 1:4 at 3, got %+v", i, *p)
+		}
+	}
+}